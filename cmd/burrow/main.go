@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
@@ -14,10 +17,17 @@ import (
 	"time"
 
 	"github.com/janiskrasemann/burrow/internal/aggregator"
+	"github.com/janiskrasemann/burrow/internal/cache"
 	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/digest"
 	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/filter"
+	"github.com/janiskrasemann/burrow/internal/logging"
 	"github.com/janiskrasemann/burrow/internal/mailer"
+	"github.com/janiskrasemann/burrow/internal/monitor"
 	"github.com/janiskrasemann/burrow/internal/renderer"
+	"github.com/janiskrasemann/burrow/internal/state"
+	"github.com/janiskrasemann/burrow/templates"
 	"github.com/robfig/cron/v3"
 )
 
@@ -25,119 +35,105 @@ import (
 var headerImage []byte
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve-preview" {
+		servePreview(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		validateConfig(os.Args[2:])
+		return
+	}
+
 	configPath := flag.String("config", "/etc/burrow/config.yaml", "path to config file")
 	once := flag.Bool("once", false, "run once immediately and exit")
 	test := flag.Bool("test", false, "render digest and open HTML in browser instead of sending email")
+	sendTest := flag.Bool("send-test", false, "send the next digest to email.test_to without advancing the edition")
+	dryRun := flag.Bool("dry-run", false, "render the next digest and write HTML, text and results JSON to -out")
+	outDir := flag.String("out", "dry-run", "output directory for -dry-run")
 	flag.Parse()
 
 	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", err)
 	}
+	setupLogging(cfg)
 
-	htmlTpl, err := os.ReadFile("templates/digest.html")
+	rend, err := loadRenderer(cfg.TemplatesDir)
 	if err != nil {
-		log.Fatalf("Failed to read HTML template: %v", err)
+		fatal("Failed to initialize renderer", err)
 	}
-	textTpl, err := os.ReadFile("templates/digest.txt")
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	store, err := state.Open(cfg.DataDir)
 	if err != nil {
-		log.Fatalf("Failed to read text template: %v", err)
+		fatal("Failed to open state store", dataDirError(err))
+	}
+	if err := store.MigrateEdition(cfg.Edition); err != nil {
+		fatal("Failed to migrate edition counter", dataDirError(err))
 	}
 
-	rend, err := renderer.New(string(htmlTpl), string(textTpl))
+	// Location names are resolved here, once, and cached in the state. An
+	// unknown or ambiguous name stops Burrow; one the geocoder could not be
+	// reached for is looked up again on fetch.
+	env := fetcher.Env{Client: httpClient, Geocoder: newGeocoder(httpClient, store)}
+	sources, err := buildSources(cfg.Sources, env)
 	if err != nil {
-		log.Fatalf("Failed to initialize renderer: %v", err)
+		fatal("Invalid config", err)
 	}
 
-	httpClient := &http.Client{Timeout: 30 * time.Second}
+	agg := aggregator.New(sources...).WithPolicy(fetchPolicies(cfg.Sources))
 
-	var fetchers []fetcher.Fetcher
-	var unsplashFetcher *fetcher.Unsplash
-	for _, src := range cfg.Sources {
-		switch src.Type {
-		case "weather":
-			fetchers = append(fetchers, fetcher.NewWeather(httpClient, src.Latitude, src.Longitude, src.Name))
-		case "readwise":
-			fetchers = append(fetchers, fetcher.NewReadwise(httpClient, src.APIToken))
-		case "hackernews":
-			fetchers = append(fetchers, fetcher.NewHackerNews(httpClient))
-		case "reddit":
-			subs := src.Subreddits
-			if len(subs) == 0 && src.Subreddit != "" {
-				subs = []string{src.Subreddit}
-			}
-			fetchers = append(fetchers, fetcher.NewReddit(subs))
-		case "nitter":
-			fetchers = append(fetchers, fetcher.NewNitter(httpClient, src.NitterInstance, src.Usernames, src.Limit))
-		case "unsplash":
-			unsplashFetcher = fetcher.NewUnsplash(httpClient, src.APIToken, src.Query)
-		default:
-			log.Fatalf("Unknown source type: %q", src.Type)
-		}
+	mail, err := mailer.New(cfg.Email, headerImage)
+	if err != nil {
+		fatal("Failed to initialize mailer", err)
 	}
 
-	agg := aggregator.New(fetchers...)
-
-	mail := mailer.New(cfg.Email.From, cfg.Email.To, cfg.Email.ResendAPIKey, headerImage)
-
-	runDigest := func() {
-		log.Println("Starting digest generation...")
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer cancel()
+	filters, err := filter.New(cfg.Filters.FilterRules, cfg.Sources)
+	if err != nil {
+		fatal("Invalid config", err)
+	}
 
-		// Reload config to get current edition number
-		latestCfg, err := config.Load(*configPath)
+	var fallback *cache.Cache
+	if cfg.Fallback.MaxAge > 0 {
+		fallback, err = cache.Open(cfg.DataDir)
 		if err != nil {
-			log.Printf("Failed to reload config: %v", err)
-			return
+			fatal("Failed to open cache", dataDirError(err))
 		}
-		edition := latestCfg.Edition + 1
+	}
 
-		results := agg.FetchAll(ctx)
-		results = fetchUnsplash(ctx, unsplashFetcher, results)
+	runner := digest.New(cfg, agg, rend, mail, store, filters, fallback)
 
-		email, err := rend.Render(results, edition)
-		if err != nil {
-			log.Printf("Failed to render digest: %v", err)
-			return
-		}
-
-		if err := mail.Send(email); err != nil {
-			log.Printf("Failed to send digest: %v", err)
-			return
-		}
+	runDigest := func(recipients []config.Recipient) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
 
-		if err := config.IncrementEdition(*configPath); err != nil {
-			log.Printf("Failed to update edition counter: %v", err)
+		if err := runner.Run(ctx, recipients); err != nil {
+			slog.ErrorContext(ctx, "Failed to send digest", "error", err)
 		}
-
-		log.Printf("Digest #%d sent successfully!", edition)
 	}
 
 	if *test {
-		log.Println("Test mode: rendering digest and opening in browser...")
+		slog.Info("Test mode: rendering digest and opening in browser")
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 		defer cancel()
 
-		results := agg.FetchAll(ctx)
-		results = fetchUnsplash(ctx, unsplashFetcher, results)
-
-		email, err := rend.Render(results, cfg.Edition+1)
+		_, email, err := runner.Preview(ctx)
 		if err != nil {
-			log.Fatalf("Failed to render digest: %v", err)
+			fatal("Failed to render digest", err)
 		}
 
 		f, err := os.CreateTemp("", "burrow-digest-*.html")
 		if err != nil {
-			log.Fatalf("Failed to create temp file: %v", err)
+			fatal("Failed to create temp file", err)
 		}
 		if _, err := f.WriteString(email.HTML); err != nil {
 			f.Close()
-			log.Fatalf("Failed to write HTML: %v", err)
+			fatal("Failed to write HTML", err)
 		}
 		f.Close()
 
-		log.Printf("HTML written to %s", f.Name())
+		slog.Info("HTML written", "path", f.Name())
 
 		var cmd string
 		switch runtime.GOOS {
@@ -149,59 +145,204 @@ func main() {
 			cmd = "open"
 		}
 		if err := exec.Command(cmd, f.Name()).Start(); err != nil {
-			log.Printf("Failed to open browser: %v", err)
+			slog.Warn("Failed to open browser", "error", err)
+		}
+		return
+	}
+
+	if *sendTest {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		if err := runner.SendTest(ctx); err != nil {
+			fatal("Failed to send test digest", err)
+		}
+		return
+	}
+
+	if *dryRun {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		d, email, err := runner.Preview(ctx)
+		if err != nil {
+			fatal("Failed to render digest", err)
+		}
+		if err := digest.WriteDryRun(*outDir, d, email); err != nil {
+			fatal("Failed to write dry run", err)
 		}
+		slog.Info("Dry run written", "dir", *outDir)
 		return
 	}
 
 	if *once {
-		runDigest()
+		runDigest(cfg.Email.Recipients)
 		return
 	}
 
 	c := cron.New()
-	_, err = c.AddFunc(cfg.Schedule, runDigest)
-	if err != nil {
-		log.Fatalf("Failed to add cron schedule %q: %v", cfg.Schedule, err)
+	for schedule, recipients := range bySchedule(cfg.Email.Recipients) {
+		if _, err := c.AddFunc(schedule, func() { runDigest(recipients) }); err != nil {
+			fatal("Failed to add cron schedule", err, "schedule", schedule)
+		}
+		slog.Info("Scheduled digest", "schedule", schedule, "recipients", len(recipients))
 	}
 	c.Start()
 
-	log.Printf("Burrow started. Schedule: %s", cfg.Schedule)
+	var srv *http.Server
+	if addr := cfg.Monitoring.Listen; addr != "" {
+		var last *state.Run
+		if runs := store.Runs(); len(runs) > 0 {
+			last = &runs[len(runs)-1]
+		}
+		mon := monitor.New(nextRun(c), last)
+		runner.OnRun(mon.ObserveRun)
+
+		srv = &http.Server{Addr: addr, Handler: mon.Handler()}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("Monitoring listener failed", err)
+			}
+		}()
+		slog.Info("Serving /healthz and /metrics", "addr", addr)
+	}
+
+	slog.Info("Burrow started")
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 
-	log.Println("Shutting down...")
+	slog.Info("Shutting down")
+	if srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}
 	c.Stop()
 }
 
-// fetchUnsplash runs the Unsplash fetcher after the aggregator, using the
-// Readwise highlight's BookTitle as the topic query for contextual imagery.
-func fetchUnsplash(ctx context.Context, uf *fetcher.Unsplash, results []fetcher.Result) []fetcher.Result {
-	if uf == nil {
-		return results
-	}
-
-	// Extract BookTitle from Readwise results for topic-aware image search
-	for _, r := range results {
-		if r.Name == "Readwise" && r.Error == nil {
-			if highlights, ok := r.Data.([]fetcher.Highlight); ok && len(highlights) > 0 {
-				if highlights[0].BookTitle != "" {
-					uf.SetTopicQuery(highlights[0].BookTitle)
+// nextRun reports the earliest next run of the scheduler's entries. The
+// scheduler answers from its own goroutine, so a stuck scheduler makes it
+// time out.
+func nextRun(c *cron.Cron) monitor.NextRunFunc {
+	return func(ctx context.Context) (time.Time, error) {
+		entries := make(chan []cron.Entry, 1)
+		go func() { entries <- c.Entries() }()
+
+		select {
+		case <-ctx.Done():
+			return time.Time{}, fmt.Errorf("scheduler did not respond")
+		case es := <-entries:
+			var next time.Time
+			for _, e := range es {
+				if next.IsZero() || e.Next.Before(next) {
+					next = e.Next
 				}
 			}
+			return next, nil
 		}
 	}
+}
 
-	data, err := uf.Fetch(ctx)
-	results = append(results, fetcher.Result{
-		Name:  uf.Name(),
-		Data:  data,
-		Error: err,
-	})
+// bySchedule groups recipients by their cron schedule, so recipients sharing
+// a schedule get the same edition from a single fetch.
+func bySchedule(recipients []config.Recipient) map[string][]config.Recipient {
+	groups := make(map[string][]config.Recipient)
+	for _, r := range recipients {
+		groups[r.Schedule] = append(groups[r.Schedule], r)
+	}
+	return groups
+}
+
+// loadRenderer builds the renderer from the embedded templates, with any
+// files in dir taking precedence.
+func loadRenderer(dir string) (*renderer.Renderer, error) {
+	htmlTpl, err := templates.Read(dir, templates.HTML)
 	if err != nil {
-		log.Printf("Unsplash fetch failed: %v", err)
+		return nil, err
+	}
+	textTpl, err := templates.Read(dir, templates.Text)
+	if err != nil {
+		return nil, err
+	}
+	return renderer.New(htmlTpl, textTpl)
+}
+
+// buildSources creates a fetcher for every configured source through the
+// fetcher registry. Sources without a title use the fetcher's name.
+func buildSources(configs []config.SourceConfig, env fetcher.Env) ([]fetcher.Source, error) {
+	sources := make([]fetcher.Source, 0, len(configs))
+	for _, src := range configs {
+		f, err := fetcher.Build(src.Type, env, src)
+		if err != nil {
+			return nil, fmt.Errorf("source %q (line %d): %w", src.ID, src.Line, err)
+		}
+		title := src.Title
+		if title == "" {
+			title = f.Name()
+		}
+		sources = append(sources, fetcher.Source{Type: src.Type, ID: src.ID, Title: title, Fetcher: f})
 	}
-	return results
+	return sources, nil
+}
+
+// fetchPolicies returns the timeout and retry policy of each configured
+// source.
+func fetchPolicies(configs []config.SourceConfig) aggregator.PolicyFunc {
+	byID := make(map[string]aggregator.Policy, len(configs))
+	for _, src := range configs {
+		byID[src.ID] = aggregator.NewPolicy(src.Fetch)
+	}
+	return func(src fetcher.Source) aggregator.Policy {
+		return byID[src.ID]
+	}
+}
+
+// newGeocoder returns a geocoder that caches the places it resolves in store.
+// A nil store caches nothing.
+func newGeocoder(client *http.Client, store *state.Store) *fetcher.Geocoder {
+	if store == nil {
+		return fetcher.NewGeocoder(client, nil)
+	}
+	return fetcher.NewGeocoder(client, placeCache{store})
+}
+
+// placeCache converts between the geocoder's places and the state's.
+type placeCache struct {
+	store *state.Store
+}
+
+func (c placeCache) Place(query string) (fetcher.Place, bool) {
+	p, ok := c.store.Place(query)
+	return fetcher.Place(p), ok
+}
+
+func (c placeCache) SetPlace(query string, p fetcher.Place) error {
+	return c.store.SetPlace(query, state.Place(p))
+}
+
+// setupLogging makes the configured logger the default, so every package
+// logs through it.
+func setupLogging(cfg *config.Config) {
+	logger, err := logging.New(os.Stderr, cfg.Log, cfg.Secrets())
+	if err != nil {
+		fatal("Invalid config", err)
+	}
+	slog.SetDefault(logger)
+}
+
+// dataDirError points out data_dir when Burrow may not write to it, as is the
+// case for the default /var/lib/burrow unless Burrow runs as root.
+func dataDirError(err error) error {
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("%w; set data_dir in the config to a directory Burrow can write to", err)
+	}
+	return err
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/aggregator"
+	"github.com/janiskrasemann/burrow/internal/cache"
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/digest"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/filter"
+	"github.com/janiskrasemann/burrow/internal/preview"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+// servePreview implements `burrow serve-preview`: fetch every source once,
+// then serve the digest rendered from the templates on disk.
+func servePreview(args []string) {
+	fs := flag.NewFlagSet("serve-preview", flag.ExitOnError)
+	configPath := fs.String("config", "/etc/burrow/config.yaml", "path to config file")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	templatesDir := fs.String("templates", "templates", "directory to reload templates from on every request; missing files use the embedded defaults")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("Failed to load config", err)
+	}
+	setupLogging(cfg)
+
+	// Fail early on broken templates rather than on the first request.
+	rend, err := loadRenderer(*templatesDir)
+	if err != nil {
+		fatal("Failed to initialize renderer", err)
+	}
+
+	// The preview never writes state, and the data dir of a deployed config
+	// usually doesn't exist on a dev machine, so the state and cache are
+	// opened read-only: location names the daemon has not resolved are looked
+	// up without caching them.
+	store, err := state.OpenReadOnly(cfg.DataDir)
+	if err != nil {
+		fatal("Failed to open state store", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	sources, err := buildSources(cfg.Sources, fetcher.Env{Client: client, Geocoder: newGeocoder(client, store)})
+	if err != nil {
+		fatal("Invalid config", err)
+	}
+
+	filters, err := filter.New(cfg.Filters.FilterRules, cfg.Sources)
+	if err != nil {
+		fatal("Invalid config", err)
+	}
+
+	var fallback *cache.Cache
+	if cfg.Fallback.MaxAge > 0 {
+		fallback = cache.OpenReadOnly(cfg.DataDir)
+	}
+
+	agg := aggregator.New(sources...).WithPolicy(fetchPolicies(cfg.Sources))
+	runner := digest.New(cfg, agg, rend, nil, store, filters, fallback)
+
+	slog.Info("Fetching sources")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	d := runner.Fetch(ctx, nil, runner.NextEdition())
+	cancel()
+	d.Explain = true
+
+	load := func() (*renderer.Renderer, error) { return loadRenderer(*templatesDir) }
+	srv := preview.New(d, load, headerImage)
+
+	slog.Info("Serving preview (text at /text; simulate with ?fail=<id> or ?empty=<id>)", "url", "http://"+*addr+"/")
+	if err := http.ListenAndServe(*addr, srv.Handler()); err != nil {
+		fatal("Preview server failed", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/filter"
+	"github.com/janiskrasemann/burrow/internal/mailer"
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+// validateConfig implements `burrow validate`: check the config file without
+// fetching or sending anything and print every problem with its line, or
+// print the config's JSON Schema with -schema.
+func validateConfig(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "/etc/burrow/config.yaml", "path to config file")
+	schema := fs.Bool("schema", false, "print the JSON Schema of the config file instead")
+	fs.Parse(args)
+
+	if *schema {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(config.Schema(fetcher.OptionTypes())); err != nil {
+			fatal("Failed to write schema", err)
+		}
+		return
+	}
+
+	problems := checkConfig(*configPath)
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Printf("%s: OK\n", *configPath)
+}
+
+// checkConfig loads the config and runs every check the daemon would run on
+// start, collecting all problems instead of stopping at the first, sorted by
+// line.
+func checkConfig(path string) []error {
+	cfg, err := config.Load(path)
+	problems := split(err)
+	if cfg == nil {
+		return problems
+	}
+
+	env := validateEnv(cfg.DataDir)
+	for _, src := range cfg.Sources {
+		for _, err := range split(fetcher.Validate(src.Type, env, src)) {
+			var located *config.Error
+			var opt *fetcher.OptionError
+			switch {
+			case errors.As(err, &located):
+				problems = append(problems, located)
+			case errors.As(err, &opt):
+				problems = append(problems, &config.Error{Line: src.OptionLine(opt.Key), Msg: fmt.Sprintf("source %q: %v", src.ID, opt)})
+			default:
+				problems = append(problems, &config.Error{Line: src.Line, Msg: fmt.Sprintf("source %q: %v", src.ID, err)})
+			}
+		}
+	}
+	if _, err := filter.New(cfg.Filters.FilterRules, cfg.Sources); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := mailer.New(cfg.Email, nil); err != nil {
+		problems = append(problems, &config.Error{Line: cfg.Line("email"), Msg: err.Error()})
+	}
+	if _, err := loadRenderer(cfg.TemplatesDir); err != nil {
+		problems = append(problems, &config.Error{Line: cfg.Line("templates_dir"), Msg: err.Error()})
+	}
+
+	slices.SortStableFunc(problems, func(a, b error) int { return line(a) - line(b) })
+	return problems
+}
+
+// validateEnv returns the env to resolve location names with. Names the
+// daemon has already resolved are taken from the state, if there is one; the
+// state is never written.
+func validateEnv(dataDir string) fetcher.Env {
+	client := &http.Client{Timeout: 30 * time.Second}
+	store, _ := state.OpenReadOnly(dataDir) // nil if unreadable
+	return fetcher.Env{Client: client, Geocoder: newGeocoder(client, store)}
+}
+
+// split returns the errors joined in err, one per problem.
+func split(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, split(e)...)
+	}
+	return errs
+}
+
+// line returns the line of a problem in the config file; problems without
+// one sort last.
+func line(err error) int {
+	var located *config.Error
+	if errors.As(err, &located) && located.Line > 0 {
+		return located.Line
+	}
+	return int(^uint(0) >> 1)
+}
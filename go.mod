@@ -8,4 +8,4 @@ require (
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/yuin/goldmark v1.7.16 // indirect
+require github.com/yuin/goldmark v1.7.16
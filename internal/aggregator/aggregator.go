@@ -2,43 +2,130 @@ package aggregator
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/janiskrasemann/burrow/internal/fetcher"
 )
 
+// ScreenFunc returns the screen to apply to one source's items during a run,
+// or nil to leave the source unscreened.
+type ScreenFunc func(src fetcher.Source) fetcher.Screen
+
 type Aggregator struct {
-	fetchers []fetcher.Fetcher
+	sources []fetcher.Source
+	// policy is nil to fetch every source once.
+	policy PolicyFunc
+}
+
+func New(sources ...fetcher.Source) *Aggregator {
+	return &Aggregator{sources: sources}
+}
+
+// Subset returns an aggregator over the sources for which keep returns true.
+func (a *Aggregator) Subset(keep func(src fetcher.Source) bool) *Aggregator {
+	var sources []fetcher.Source
+	for _, src := range a.sources {
+		if keep(src) {
+			sources = append(sources, src)
+		}
+	}
+	return &Aggregator{sources: sources, policy: a.policy}
 }
 
-func New(fetchers ...fetcher.Fetcher) *Aggregator {
-	return &Aggregator{fetchers: fetchers}
+// WithPolicy returns an aggregator over the same sources that times out and
+// retries each source according to policy.
+func (a *Aggregator) WithPolicy(policy PolicyFunc) *Aggregator {
+	return &Aggregator{sources: a.sources, policy: policy}
 }
 
-func (a *Aggregator) FetchAll(ctx context.Context) []fetcher.Result {
-	results := make([]fetcher.Result, len(a.fetchers))
+// FetchAll runs all sources concurrently and returns their results in the
+// order the sources were given. Dependent fetchers run afterwards, once the
+// results they depend on are available. The given screens are applied to the
+// candidate items of every source. Failed fetches are retried as the policy
+// allows.
+func (a *Aggregator) FetchAll(ctx context.Context, screens ...ScreenFunc) []fetcher.Result {
+	results := make([]fetcher.Result, len(a.sources))
 	var wg sync.WaitGroup
 
-	for i, f := range a.fetchers {
+	var dependents []int
+	for i, src := range a.sources {
+		if _, ok := src.Fetcher.(fetcher.Dependent); ok {
+			dependents = append(dependents, i)
+			continue
+		}
 		wg.Add(1)
-		go func(idx int, ft fetcher.Fetcher) {
+		go func(idx int, src fetcher.Source) {
 			defer wg.Done()
-			log.Printf("Fetching %s...", ft.Name())
-			data, err := ft.Fetch(ctx)
-			if err != nil {
-				log.Printf("Error fetching %s: %v", ft.Name(), err)
-			} else {
-				log.Printf("Fetched %s successfully", ft.Name())
-			}
-			results[idx] = fetcher.Result{
-				Name:  ft.Name(),
-				Data:  data,
-				Error: err,
-			}
-		}(i, f)
+			results[idx] = a.fetch(screened(ctx, src, screens), src)
+		}(i, src)
 	}
 
 	wg.Wait()
+
+	for _, idx := range dependents {
+		a.sources[idx].Fetcher.(fetcher.Dependent).Prepare(results)
+		results[idx] = a.fetch(screened(ctx, a.sources[idx], screens), a.sources[idx])
+	}
+
 	return results
 }
+
+func screened(ctx context.Context, src fetcher.Source, screens []ScreenFunc) context.Context {
+	for _, sf := range screens {
+		if s := sf(src); s != nil {
+			ctx = fetcher.WithScreen(ctx, s)
+		}
+	}
+	return ctx
+}
+
+// fetch fetches one source, retrying failed attempts within its timeout.
+func (a *Aggregator) fetch(ctx context.Context, src fetcher.Source) fetcher.Result {
+	var p Policy
+	if a.policy != nil {
+		p = a.policy(src)
+	}
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	slog.DebugContext(ctx, "Fetching source", "source", src.ID)
+	start := time.Now()
+	var data any
+	var err error
+	for attempt := 1; ; attempt++ {
+		data, err = src.Fetcher.Fetch(ctx)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		delay, ok := p.retryDelay(attempt, err)
+		if !ok {
+			break
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			break
+		}
+		slog.WarnContext(ctx, "Fetch failed, retrying", "source", src.ID, "attempt", attempt, "attempts", p.Attempts, "delay", delay.Round(time.Millisecond), "error", err)
+		if sleep(ctx, delay) != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch source", "source", src.ID, "duration", time.Since(start), "error", err)
+	} else {
+		slog.InfoContext(ctx, "Fetched source", "source", src.ID, "duration", time.Since(start), "items", fetcher.Count(data))
+	}
+	return fetcher.Result{
+		Type:     src.Type,
+		ID:       src.ID,
+		Title:    src.Title,
+		Data:     data,
+		Error:    err,
+		Duration: time.Since(start),
+	}
+}
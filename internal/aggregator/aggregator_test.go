@@ -0,0 +1,108 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+type stubFetcher struct {
+	name string
+	data any
+	err  error
+}
+
+func (s *stubFetcher) Name() string { return s.name }
+
+func (s *stubFetcher) Fetch(ctx context.Context) (any, error) { return s.data, s.err }
+
+func source(id string, f fetcher.Fetcher) fetcher.Source {
+	return fetcher.Source{Type: "stub", ID: id, Title: id, Fetcher: f}
+}
+
+type dependentStub struct {
+	stubFetcher
+	seen []string
+}
+
+func (d *dependentStub) Prepare(results []fetcher.Result) {
+	for _, r := range results {
+		if r.ID != "" {
+			d.seen = append(d.seen, r.ID)
+		}
+	}
+}
+
+func TestFetchAllOrderAndErrors(t *testing.T) {
+	agg := New(
+		source("a", &stubFetcher{name: "A", data: 1}),
+		source("b", &stubFetcher{name: "B", err: fmt.Errorf("boom")}),
+		source("c", &stubFetcher{name: "C", data: 3}),
+	)
+
+	results := agg.FetchAll(context.Background())
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if results[i].ID != want {
+			t.Errorf("result %d: expected %q, got %q", i, want, results[i].ID)
+		}
+	}
+	if results[1].Error == nil {
+		t.Error("expected error for B")
+	}
+}
+
+func TestFetchAllDependentRunsLast(t *testing.T) {
+	dep := &dependentStub{stubFetcher: stubFetcher{name: "D", data: "img"}}
+	agg := New(source("d", dep), source("a", &stubFetcher{name: "A", data: 1}), source("b", &stubFetcher{name: "B", data: 2}))
+
+	results := agg.FetchAll(context.Background())
+
+	if len(dep.seen) != 2 {
+		t.Fatalf("expected dependent to see 2 results, saw %v", dep.seen)
+	}
+	if results[0].ID != "d" || results[0].Data != "img" {
+		t.Errorf("expected dependent result in its configured position, got %+v", results[0])
+	}
+}
+
+type screeningStub struct {
+	items []fetcher.HNPost
+}
+
+func (s *screeningStub) Name() string { return "screening" }
+
+func (s *screeningStub) Fetch(ctx context.Context) (any, error) {
+	var kept []fetcher.HNPost
+	for _, p := range s.items {
+		if fetcher.Admit(ctx, p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
+
+func TestFetchAllAppliesScreensPerSource(t *testing.T) {
+	items := []fetcher.HNPost{{URL: "https://a.com"}, {URL: "https://b.com"}}
+	agg := New(source("one", &screeningStub{items: items}), source("two", &screeningStub{items: items}))
+
+	dropB := func(src fetcher.Source) fetcher.Screen {
+		if src.ID != "one" {
+			return nil
+		}
+		return func(item fetcher.Item) bool { return item.Key() != "b.com" }
+	}
+
+	results := agg.FetchAll(context.Background(), dropB)
+
+	if got := len(results[0].Data.([]fetcher.HNPost)); got != 1 {
+		t.Errorf("expected screened source to keep 1 item, got %d", got)
+	}
+	if got := len(results[1].Data.([]fetcher.HNPost)); got != 2 {
+		t.Errorf("expected unscreened source to keep 2 items, got %d", got)
+	}
+}
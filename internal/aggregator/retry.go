@@ -0,0 +1,106 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+// Policy controls how a source is fetched. The zero Policy fetches once,
+// bounded only by the run's context.
+type Policy struct {
+	// Timeout bounds all attempts together.
+	Timeout time.Duration
+	// Attempts is the maximum number of fetches, the first one included.
+	Attempts int
+	// Backoff is the delay before the first retry; it doubles with every
+	// further retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// RetryOn lists the retry classes (config.RetryNetwork etc.) to retry.
+	RetryOn []string
+}
+
+// PolicyFunc returns the fetch policy of a source.
+type PolicyFunc func(src fetcher.Source) Policy
+
+// NewPolicy converts a source's fetch config into a Policy.
+func NewPolicy(c config.FetchConfig) Policy {
+	return Policy{
+		Timeout:    c.Timeout,
+		Attempts:   c.Retry.Attempts,
+		Backoff:    c.Retry.Backoff,
+		MaxBackoff: c.Retry.MaxBackoff,
+		RetryOn:    c.Retry.RetryOn,
+	}
+}
+
+// retryDelay returns how long to wait before the given retry (1 for the first)
+// after err, and false if err should not be retried.
+func (p Policy) retryDelay(retry int, err error) (time.Duration, bool) {
+	if retry >= p.Attempts || !slices.Contains(p.RetryOn, classify(err)) {
+		return 0, false
+	}
+
+	delay := p.Backoff << (retry - 1)
+	if (p.MaxBackoff > 0 && delay > p.MaxBackoff) || delay < 0 {
+		delay = p.MaxBackoff
+	}
+	// Equal jitter: wait at least half the delay so retries still back off,
+	// but spread them out so concurrent sources don't retry in lockstep.
+	if delay > 1 {
+		delay = delay/2 + rand.N(delay/2)
+	}
+
+	var se *fetcher.StatusError
+	if errors.As(err, &se) && se.RetryAfter > delay {
+		if p.MaxBackoff > 0 && se.RetryAfter > p.MaxBackoff {
+			return 0, false
+		}
+		delay = se.RetryAfter
+	}
+	return delay, true
+}
+
+// classify returns the retry class of a fetch error, or "" if retrying it is
+// pointless.
+func classify(err error) string {
+	var se *fetcher.StatusError
+	if errors.As(err, &se) {
+		switch {
+		case se.StatusCode == 429:
+			return config.RetryRateLimited
+		case se.StatusCode >= 500:
+			return config.RetryServerError
+		}
+		return ""
+	}
+
+	var ne net.Error
+	switch {
+	case errors.As(err, &ne) && ne.Timeout(), errors.Is(err, context.DeadlineExceeded):
+		return config.RetryTimeout
+	case ne != nil, errors.Is(err, io.ErrUnexpectedEOF):
+		return config.RetryNetwork
+	}
+	return ""
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
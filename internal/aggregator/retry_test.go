@@ -0,0 +1,161 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+// flakyFetcher fails with the given errors in turn, then succeeds.
+type flakyFetcher struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyFetcher) Name() string { return "flaky" }
+
+func (f *flakyFetcher) Fetch(ctx context.Context) (any, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return "ok", nil
+}
+
+// slowFetcher blocks until its context is done.
+type slowFetcher struct{}
+
+func (slowFetcher) Name() string { return "slow" }
+
+func (slowFetcher) Fetch(ctx context.Context) (any, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func retryAll(attempts int) PolicyFunc {
+	return func(fetcher.Source) Policy {
+		return Policy{
+			Attempts:   attempts,
+			Backoff:    time.Millisecond,
+			MaxBackoff: 10 * time.Millisecond,
+			RetryOn:    []string{config.RetryNetwork, config.RetryTimeout, config.RetryServerError, config.RetryRateLimited},
+		}
+	}
+}
+
+func TestFetchRetriesTransientErrors(t *testing.T) {
+	f := &flakyFetcher{errs: []error{
+		&fetcher.StatusError{StatusCode: 502},
+		fmt.Errorf("reading body: %w", context.DeadlineExceeded),
+	}}
+	agg := New(source("a", f)).WithPolicy(retryAll(3))
+
+	results := agg.FetchAll(context.Background())
+
+	if results[0].Error != nil || results[0].Data != "ok" {
+		t.Fatalf("expected success after retries, got %+v", results[0])
+	}
+	if f.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", f.calls)
+	}
+}
+
+func TestFetchGivesUpAfterAttempts(t *testing.T) {
+	f := &flakyFetcher{errs: []error{
+		&fetcher.StatusError{StatusCode: 503},
+		&fetcher.StatusError{StatusCode: 503},
+		&fetcher.StatusError{StatusCode: 503},
+	}}
+	agg := New(source("a", f)).WithPolicy(retryAll(2))
+
+	results := agg.FetchAll(context.Background())
+
+	if results[0].Error == nil {
+		t.Fatal("expected an error")
+	}
+	if f.calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", f.calls)
+	}
+}
+
+func TestFetchDoesNotRetryPermanentErrors(t *testing.T) {
+	for _, err := range []error{
+		&fetcher.StatusError{StatusCode: 404},
+		fmt.Errorf("decoding response: invalid character"),
+	} {
+		f := &flakyFetcher{errs: []error{err}}
+		New(source("a", f)).WithPolicy(retryAll(3)).FetchAll(context.Background())
+		if f.calls != 1 {
+			t.Errorf("%v: expected 1 attempt, got %d", err, f.calls)
+		}
+	}
+}
+
+func TestFetchRetriesOnlySelectedClasses(t *testing.T) {
+	f := &flakyFetcher{errs: []error{&fetcher.StatusError{StatusCode: 429}}}
+	only5xx := func(fetcher.Source) Policy {
+		return Policy{Attempts: 3, Backoff: time.Millisecond, RetryOn: []string{config.RetryServerError}}
+	}
+
+	New(source("a", f)).WithPolicy(only5xx).FetchAll(context.Background())
+
+	if f.calls != 1 {
+		t.Errorf("expected 429 not to be retried, got %d attempts", f.calls)
+	}
+}
+
+func TestFetchHonorsRetryAfter(t *testing.T) {
+	f := &flakyFetcher{errs: []error{&fetcher.StatusError{StatusCode: 429, RetryAfter: 50 * time.Millisecond}}}
+	policy := func(fetcher.Source) Policy {
+		return Policy{Attempts: 2, Backoff: time.Millisecond, MaxBackoff: time.Second, RetryOn: []string{config.RetryRateLimited}}
+	}
+
+	start := time.Now()
+	results := New(source("a", f)).WithPolicy(policy).FetchAll(context.Background())
+
+	if results[0].Error != nil {
+		t.Fatalf("unexpected error: %v", results[0].Error)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected to wait for Retry-After, retried after %s", elapsed)
+	}
+}
+
+func TestFetchGivesUpOnRetryAfterBeyondMaxBackoff(t *testing.T) {
+	f := &flakyFetcher{errs: []error{&fetcher.StatusError{StatusCode: 429, RetryAfter: time.Hour}}}
+
+	results := New(source("a", f)).WithPolicy(retryAll(3)).FetchAll(context.Background())
+
+	if results[0].Error == nil || f.calls != 1 {
+		t.Errorf("expected to give up at once, got %d attempts", f.calls)
+	}
+}
+
+func TestFetchPerSourceTimeout(t *testing.T) {
+	policy := func(src fetcher.Source) Policy {
+		if src.ID == "slow" {
+			return Policy{Timeout: 20 * time.Millisecond}
+		}
+		return Policy{}
+	}
+	agg := New(source("slow", slowFetcher{}), source("fast", &stubFetcher{name: "F", data: 1})).WithPolicy(policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	results := agg.FetchAll(ctx)
+
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the slow source to time out early, took %s", time.Since(start))
+	}
+	if results[0].Error == nil {
+		t.Error("expected the slow source to fail")
+	}
+	if results[1].Error != nil || results[1].Data != 1 {
+		t.Errorf("expected the fast source to succeed, got %+v", results[1])
+	}
+}
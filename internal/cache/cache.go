@@ -0,0 +1,128 @@
+// Package cache keeps the last successful result of every source on disk, so
+// that a section whose fetch fails can show the previous copy instead.
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+// dirName is the cache directory under the data directory.
+const dirName = "cache"
+
+// Entry is a cached result.
+type Entry struct {
+	Data    any
+	SavedAt time.Time
+}
+
+type document struct {
+	Type    string          `json:"type"`
+	SavedAt time.Time       `json:"saved_at"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Cache stores one JSON file per source under the data directory.
+type Cache struct {
+	dir string
+}
+
+// Open returns the cache under dataDir, creating its directory if needed.
+func Open(dataDir string) (*Cache, error) {
+	dir := filepath.Join(dataDir, dirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache dir: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// OpenReadOnly returns the cache under dataDir without creating anything, for
+// callers that only load from it. A missing directory reads as an empty cache.
+func OpenReadOnly(dataDir string) *Cache {
+	return &Cache{dir: filepath.Join(dataDir, dirName)}
+}
+
+// Save stores a successful result as the source's latest copy.
+func (c *Cache) Save(res fetcher.Result, at time.Time) error {
+	data, err := json.Marshal(res.Data)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", res.ID, err)
+	}
+	raw, err := json.Marshal(document{Type: res.Type, SavedAt: at, Data: data})
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", res.ID, err)
+	}
+
+	// Write via a temp file so a crash never leaves a truncated copy behind.
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(res.ID)); err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	return nil
+}
+
+// Load returns the cached copy of a source, or nil if there is none. A copy
+// saved for a different source type is ignored.
+func (c *Cache) Load(sourceID, typ string) (*Entry, error) {
+	raw, err := os.ReadFile(c.path(sourceID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache: %w", err)
+	}
+
+	var doc document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parsing cached %s: %w", sourceID, err)
+	}
+	if doc.Type != typ {
+		return nil, nil
+	}
+	data, err := fetcher.DecodeData(doc.Type, doc.Data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cached %s: %w", sourceID, err)
+	}
+	return &Entry{Data: data, SavedAt: doc.SavedAt}, nil
+}
+
+// Fallback replaces a failed result with the source's cached copy if that is
+// younger than maxAge. Other results are returned unchanged.
+func (c *Cache) Fallback(res fetcher.Result, maxAge time.Duration, now time.Time) (fetcher.Result, error) {
+	if res.Error == nil {
+		return res, nil
+	}
+	entry, err := c.Load(res.ID, res.Type)
+	if err != nil || entry == nil || now.Sub(entry.SavedAt) > maxAge {
+		return res, err
+	}
+	res.Data = entry.Data
+	res.CachedAt = entry.SavedAt
+	res.FetchError = res.Error
+	res.Error = nil
+	return res, nil
+}
+
+func (c *Cache) path(sourceID string) string {
+	return filepath.Join(c.dir, url.PathEscape(sourceID)+".json")
+}
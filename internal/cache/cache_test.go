@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	at := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	posts := []fetcher.RedditPost{{Title: "Hello", Score: 42, Ranking: &fetcher.Ranking{Score: 5.4}}}
+
+	if err := c.Save(fetcher.Result{Type: fetcher.TypeReddit, ID: "reddit/tech", Data: posts}, at); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entry, err := c.Load("reddit/tech", fetcher.TypeReddit)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, ok := entry.Data.([]fetcher.RedditPost)
+	if !ok || len(got) != 1 || got[0].Title != "Hello" || got[0].Ranking.Score != 5.4 {
+		t.Errorf("unexpected data %#v", entry.Data)
+	}
+	if !entry.SavedAt.Equal(at) {
+		t.Errorf("expected saved at %s, got %s", at, entry.SavedAt)
+	}
+}
+
+func TestLoadMissingOrOtherType(t *testing.T) {
+	c, _ := Open(t.TempDir())
+	c.Save(fetcher.Result{Type: fetcher.TypeWeather, ID: "main", Data: []fetcher.WeatherData{{Temperature: 12}}}, time.Now())
+
+	for _, id := range []string{"other", "main"} {
+		entry, err := c.Load(id, fetcher.TypeFeed)
+		if err != nil || entry != nil {
+			t.Errorf("%s: expected no entry, got %+v, %v", id, entry, err)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	c, _ := Open(t.TempDir())
+	now := time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)
+	c.Save(fetcher.Result{Type: fetcher.TypeWeather, ID: "weather", Data: []fetcher.WeatherData{{Temperature: 12}}}, now.Add(-24*time.Hour))
+	failed := fetcher.Result{Type: fetcher.TypeWeather, ID: "weather", Error: fmt.Errorf("HTTP 502")}
+
+	res, err := c.Fallback(failed, 36*time.Hour, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Error != nil || res.FetchError == nil || res.CachedAt.IsZero() {
+		t.Errorf("expected the cached copy to stand in, got %+v", res)
+	}
+	if w, ok := res.Data.([]fetcher.WeatherData); !ok || len(w) != 1 || w[0].Temperature != 12 {
+		t.Errorf("unexpected data %#v", res.Data)
+	}
+
+	res, _ = c.Fallback(failed, 12*time.Hour, now)
+	if res.Error == nil || res.Data != nil {
+		t.Errorf("expected a copy older than max age to be ignored, got %+v", res)
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a problem at a line of the config file.
+type Error struct {
+	// Line is 0 if the problem has no particular place in the file.
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+func errorAt(line int, format string, args ...any) error {
+	return &Error{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// sourceKeys are the keys every source entry shares, with the types they
+// decode into. All other keys belong to the source's options block.
+var sourceKeys = map[string]reflect.Type{
+	"type":    reflect.TypeFor[string](),
+	"id":      reflect.TypeFor[string](),
+	"title":   reflect.TypeFor[string](),
+	"filters": reflect.TypeFor[FilterRules](),
+	"timeout": reflect.TypeFor[time.Duration](),
+	"retry":   reflect.TypeFor[RetryConfig](),
+}
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	sourceType   = reflect.TypeFor[SourceConfig]()
+)
+
+// unknownKeys reports every mapping key below node that t has no field for.
+// what names the keys in the messages, like "key" or "reddit option".
+func unknownKeys(node *yaml.Node, t reflect.Type, what string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		if node.Kind == yaml.SequenceNode {
+			for _, item := range node.Content {
+				errs = append(errs, unknownKeys(item, t.Elem(), what)...)
+			}
+		}
+		return errs
+	case reflect.Map:
+		var errs []error
+		if node.Kind == yaml.MappingNode {
+			for i := 1; i < len(node.Content); i += 2 {
+				errs = append(errs, unknownKeys(node.Content[i], t.Elem(), what)...)
+			}
+		}
+		return errs
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	// Types with their own UnmarshalYAML take a scalar as shorthand; a
+	// mapping still decodes into their fields.
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	fields := yamlFields(t)
+	if t == sourceType {
+		fields = sourceKeys
+	}
+
+	var errs []error
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		if key.Value == "<<" {
+			errs = append(errs, unknownKeys(val, t, what)...)
+			continue
+		}
+		ft, ok := fields[key.Value]
+		switch {
+		case ok:
+			errs = append(errs, unknownKeys(val, ft, what)...)
+		case t == sourceType:
+			// An option; SourceConfig.Decode checks those.
+		default:
+			msg := fmt.Sprintf("unknown %s %q", what, key.Value)
+			if s := suggest(key.Value, fields); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			errs = append(errs, errorAt(key.Line, "%s", msg))
+		}
+	}
+	return errs
+}
+
+// yamlFields maps the YAML keys of struct t to their field types, following
+// inlined structs.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// suggest returns the known key closest to a misspelled one, if any is close
+// enough to be a likely typo.
+func suggest(key string, known map[string]reflect.Type) string {
+	best, bestDist := "", len(key)/2+1
+	for k := range known {
+		if d := editDistance(key, k); d < bestDist || (d == bestDist && best != "" && k < best) {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// unresolvedVars reports every ${VAR} placeholder left in a value after
+// expansion, i.e. every variable that is not set and has no default.
+func unresolvedVars(node *yaml.Node) []error {
+	var errs []error
+	if node.Kind == yaml.ScalarNode {
+		for _, m := range envVarPattern.FindAllStringSubmatch(node.Value, -1) {
+			errs = append(errs, errorAt(node.Line, "environment variable %s is not set", m[1]))
+		}
+	}
+	for _, child := range node.Content {
+		errs = append(errs, unresolvedVars(child)...)
+	}
+	return errs
+}
+
+// Line returns the line of the config entry at path, like "email",
+// "recipients", "0", or of its closest ancestor in the file. Sequence items
+// are addressed by their index.
+func (c *Config) Line(path ...string) int {
+	node := c.root
+	if node == nil {
+		return 0
+	}
+	line := node.Line
+	for _, p := range path {
+		next, at := child(node, p)
+		if next == nil {
+			break
+		}
+		node, line = next, at
+	}
+	return line
+}
+
+// child returns the value at key p of a mapping, or at index p of a sequence,
+// and the line it starts on.
+func child(node *yaml.Node, p string) (*yaml.Node, int) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == p {
+				return node.Content[i+1], node.Content[i].Line
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(p); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i], node.Content[i].Line
+		}
+	}
+	return nil, 0
+}
+
+// OptionLine returns the line of an option of the source entry, or of the
+// entry itself if it does not set the option.
+func (s SourceConfig) OptionLine(key string) int {
+	if _, line := child(&s.options, key); line > 0 {
+		return line
+	}
+	return s.Line
+}
+
+// check reports semantic problems Load cannot catch while decoding: invalid
+// schedules, email addresses and log settings.
+func (c *Config) check() []error {
+	var errs []error
+	if c.Schedule != "" {
+		if _, err := cron.ParseStandard(c.Schedule); err != nil {
+			errs = append(errs, errorAt(c.Line("schedule"), "invalid schedule %q: %v", c.Schedule, err))
+		}
+	}
+
+	checkAddress := func(addr string, path ...string) {
+		if addr == "" {
+			return
+		}
+		if _, err := mail.ParseAddress(addr); err != nil {
+			errs = append(errs, errorAt(c.Line(path...), "invalid email address %q", addr))
+		}
+	}
+	checkAddress(c.Email.From, "email", "from")
+	checkAddress(c.Email.TestTo, "email", "test_to")
+	if !slices.ContainsFunc(c.Email.Recipients, func(r Recipient) bool { return r.Address == c.Alerts.To }) {
+		// Otherwise it is checked, or was defaulted, as a recipient.
+		checkAddress(c.Alerts.To, "alerts", "to")
+	}
+	for i, r := range c.Email.Recipients {
+		idx := strconv.Itoa(i)
+		checkAddress(r.Address, "email", "recipients", idx)
+		if r.Schedule != "" && r.Schedule != c.Schedule {
+			if _, err := cron.ParseStandard(r.Schedule); err != nil {
+				errs = append(errs, errorAt(c.Line("email", "recipients", idx, "schedule"), "recipient %s: invalid schedule %q: %v", r.Address, r.Schedule, err))
+			}
+		}
+	}
+
+	switch c.Log.Format {
+	case "", "text", "json":
+	default:
+		errs = append(errs, errorAt(c.Line("log", "format"), "unknown log.format %q (want text or json)", c.Log.Format))
+	}
+	var level slog.Level
+	if c.Log.Level != "" && level.UnmarshalText([]byte(c.Log.Level)) != nil {
+		errs = append(errs, errorAt(c.Line("log", "level"), "unknown log.level %q (want debug, info, warn or error)", c.Log.Level))
+	}
+	return errs
+}
@@ -1,45 +1,359 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultDataDir is where the state store lives unless data_dir is set.
+const DefaultDataDir = "/var/lib/burrow"
+
 type Config struct {
-	Schedule string         `yaml:"schedule"`
-	Edition  int            `yaml:"edition"`
-	Email    EmailConfig    `yaml:"email"`
-	Sources  []SourceConfig `yaml:"sources"`
+	Schedule string `yaml:"schedule"`
+	// Edition is the legacy edition counter. The state store now owns it and
+	// only reads this value once, to migrate an existing install.
+	Edition int    `yaml:"edition"`
+	DataDir string `yaml:"data_dir"`
+	// TemplatesDir optionally overrides the embedded templates; files missing
+	// from it fall back to the defaults.
+	TemplatesDir string         `yaml:"templates_dir"`
+	Dedupe       DedupeConfig   `yaml:"dedupe"`
+	Filters      FilterConfig   `yaml:"filters"`
+	Interests    InterestConfig `yaml:"interests"`
+	Fetch        FetchConfig    `yaml:"fetch"`
+	Fallback     FallbackConfig `yaml:"fallback"`
+	Alerts       AlertConfig    `yaml:"alerts"`
+	Monitoring   MonitorConfig  `yaml:"monitoring"`
+	Log          LogConfig      `yaml:"log"`
+	Email        EmailConfig    `yaml:"email"`
+	Sources      []SourceConfig `yaml:"sources"`
+
+	// root is the parsed file, for the line numbers of problems.
+	root *yaml.Node
+}
+
+// DedupeConfig controls dropping stories that were delivered recently.
+type DedupeConfig struct {
+	// Editions is how many past editions an item is remembered for. Zero
+	// disables de-duplication.
+	Editions int `yaml:"editions"`
+}
+
+// FallbackConfig controls showing a source's last successful result when its
+// fetch fails.
+type FallbackConfig struct {
+	// MaxAge is how old a cached result may be to stand in for a failed
+	// fetch. Zero disables the cache.
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// LogConfig controls the log output.
+type LogConfig struct {
+	// Format is "text" (default) or "json".
+	Format string `yaml:"format"`
+	// Level is "debug", "info" (default), "warn" or "error".
+	Level string `yaml:"level"`
+}
+
+// MonitorConfig controls the HTTP listener of the scheduled daemon.
+type MonitorConfig struct {
+	// Listen is the address serving /healthz and /metrics, e.g. ":9464".
+	// Empty disables the listener.
+	Listen string `yaml:"listen"`
+}
+
+// Alert deliveries.
+const (
+	AlertEmail  = "email"
+	AlertDigest = "digest"
+)
+
+// AlertConfig controls alerts about sources that keep failing.
+type AlertConfig struct {
+	// After is how many runs in a row a source must fail in before an alert
+	// goes out. Zero disables alerts.
+	After int `yaml:"after"`
+	// Delivery is AlertEmail (default) to send a separate short email, or
+	// AlertDigest to show the alerts in an admin notes box in the digest.
+	Delivery string `yaml:"delivery"`
+	// To receives the alert emails; it defaults to the first recipient. With
+	// AlertDigest, only this recipient's digest shows the notes, or everyone's
+	// if it is empty.
+	To string `yaml:"to"`
+}
+
+// FilterRules hide items by their content. An item is hidden if it matches
+// any exclude rule, or if include rules are set and it matches none of them.
+type FilterRules struct {
+	// Include and Exclude are keywords matched as whole words, ignoring case,
+	// against an item's title and text.
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// IncludePatterns and ExcludePatterns are regular expressions matched
+	// against the title and text.
+	IncludePatterns []string `yaml:"include_patterns"`
+	ExcludePatterns []string `yaml:"exclude_patterns"`
+	// Domains blocks links to these domains and their subdomains.
+	Domains []string `yaml:"domains"`
+	// Authors blocks items by these authors, ignoring case.
+	Authors []string `yaml:"authors"`
+}
+
+// FilterConfig holds the global filter rules, which apply to every source in
+// addition to the source's own.
+type FilterConfig struct {
+	FilterRules `yaml:",inline"`
+	// ShowFiltered lists the hidden items in a collapsed footer instead of
+	// only counting them.
+	ShowFiltered bool `yaml:"show_filtered"`
+}
+
+// InterestConfig is the reader's interest profile. When set, items are ranked
+// by their popularity combined with how well they match it.
+type InterestConfig struct {
+	// Terms maps keywords, matched as whole words, to weights. Each point of
+	// weight doubles an item's score; negative weights halve it.
+	Terms map[string]float64 `yaml:"terms"`
+	// BoostDomains maps domains to weights like Terms.
+	BoostDomains map[string]float64 `yaml:"boost_domains"`
+	// MuteDomains ranks links to these domains last.
+	MuteDomains []string `yaml:"mute_domains"`
+}
+
+// Retry classes name the failures RetryConfig.RetryOn can select.
+const (
+	// RetryNetwork covers connection errors and truncated responses.
+	RetryNetwork = "network"
+	// RetryTimeout covers requests that timed out.
+	RetryTimeout = "timeout"
+	// RetryServerError covers HTTP 5xx responses.
+	RetryServerError = "5xx"
+	// RetryRateLimited covers HTTP 429 responses.
+	RetryRateLimited = "429"
+)
+
+var retryClasses = []string{RetryNetwork, RetryTimeout, RetryServerError, RetryRateLimited}
+
+// FetchConfig controls how long a source may take and how its failed fetches
+// are retried. The top-level block is the default for every source; a source
+// can override any of it with its own timeout and retry keys.
+type FetchConfig struct {
+	// Timeout bounds a source's fetch, retries included.
+	Timeout time.Duration `yaml:"timeout"`
+	Retry   RetryConfig   `yaml:"retry"`
+}
+
+// RetryConfig is the retry policy of a source.
+type RetryConfig struct {
+	// Attempts is the maximum number of fetches, the first one included; 1
+	// disables retries.
+	Attempts int `yaml:"attempts"`
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry up to MaxBackoff and is jittered. A server asking for a
+	// longer delay with Retry-After gets it, unless that exceeds MaxBackoff.
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// RetryOn lists the retry classes worth another attempt.
+	RetryOn []string `yaml:"retry_on"`
+}
+
+// Default fetch policy, used for everything the config leaves unset.
+var defaultFetch = FetchConfig{
+	Timeout: 45 * time.Second,
+	Retry: RetryConfig{
+		Attempts:   3,
+		Backoff:    2 * time.Second,
+		MaxBackoff: 20 * time.Second,
+		RetryOn:    retryClasses,
+	},
+}
+
+// withDefaults fills the fields left unset in c from def.
+func (c FetchConfig) withDefaults(def FetchConfig) FetchConfig {
+	if c.Timeout == 0 {
+		c.Timeout = def.Timeout
+	}
+	if c.Retry.Attempts == 0 {
+		c.Retry.Attempts = def.Retry.Attempts
+	}
+	if c.Retry.Backoff == 0 {
+		c.Retry.Backoff = def.Retry.Backoff
+	}
+	if c.Retry.MaxBackoff == 0 {
+		c.Retry.MaxBackoff = def.Retry.MaxBackoff
+	}
+	if c.Retry.RetryOn == nil {
+		c.Retry.RetryOn = def.Retry.RetryOn
+	}
+	return c
+}
+
+func (c FetchConfig) validate() error {
+	if c.Timeout < 0 || c.Retry.Backoff < 0 || c.Retry.MaxBackoff < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if c.Retry.Attempts < 1 {
+		return fmt.Errorf("retry.attempts must be at least 1")
+	}
+	for _, class := range c.Retry.RetryOn {
+		if !slices.Contains(retryClasses, class) {
+			return fmt.Errorf("unknown retry_on value %q (expected one of %s)", class, strings.Join(retryClasses, ", "))
+		}
+	}
+	return nil
 }
 
 type EmailConfig struct {
-	From         string `yaml:"from"`
-	To           string `yaml:"to"`
-	TestTo       string `yaml:"test_to"`
-	ResendAPIKey string `yaml:"resend_api_key"`
+	// Provider selects the mailer backend: "resend" (default) or "smtp".
+	Provider string `yaml:"provider"`
+	From     string `yaml:"from"`
+	// To is the single recipient of older configs. Load turns it into a
+	// Recipients entry when no recipients are listed.
+	To           string      `yaml:"to"`
+	Recipients   []Recipient `yaml:"recipients"`
+	TestTo       string      `yaml:"test_to"`
+	ResendAPIKey string      `yaml:"resend_api_key"`
+	SMTP         SMTPConfig  `yaml:"smtp"`
+}
+
+// Recipient is one person receiving the digest, with optional overrides.
+type Recipient struct {
+	Address string `yaml:"address"`
+	// Sources limits the digest to these source IDs; empty means all.
+	Sources []string `yaml:"sources"`
+	// Order lists source IDs to show first, in that order. Sources not listed
+	// follow in config order.
+	Order []string `yaml:"order"`
+	// Schedule overrides the top-level schedule for this recipient.
+	Schedule string `yaml:"schedule"`
+}
+
+// UnmarshalYAML accepts a bare address as shorthand for a recipient without
+// overrides.
+func (r *Recipient) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&r.Address)
+	}
+	type plain Recipient
+	return node.Decode((*plain)(r))
+}
+
+// Wants reports whether the recipient's digest includes the source.
+func (r Recipient) Wants(sourceID string) bool {
+	return len(r.Sources) == 0 || slices.Contains(r.Sources, sourceID)
 }
 
+type SMTPConfig struct {
+	Host string `yaml:"host"`
+	// Port defaults to 465 with implicit TLS and 587 otherwise.
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// TLS is "starttls" (default), "implicit" or "none".
+	TLS string `yaml:"tls"`
+	// Auth is "plain" or "login"; by default the first the server offers.
+	Auth string `yaml:"auth"`
+}
+
+// SourceConfig is one entry of the sources list. Type, id, title, filters,
+// timeout and retry are shared by all sources; every other key belongs to the
+// source's options block, which the fetcher registered for that type decodes
+// into its own struct.
 type SourceConfig struct {
-	Type      string  `yaml:"type"`
-	// Weather fields
-	Latitude  float64 `yaml:"latitude,omitempty"`
-	Longitude float64 `yaml:"longitude,omitempty"`
-	Name      string  `yaml:"name,omitempty"`
-	// Readwise fields
-	APIToken  string  `yaml:"api_token,omitempty"`
-	// Reddit fields
-	Subreddit  string   `yaml:"subreddit,omitempty"`
-	Subreddits []string `yaml:"subreddits,omitempty"`
-	// Nitter fields
-	NitterInstance string   `yaml:"nitter_instance,omitempty"`
-	Usernames      []string `yaml:"usernames,omitempty"`
-	Limit          int      `yaml:"limit,omitempty"`
-	// Unsplash fields
-	Query string `yaml:"query,omitempty"`
+	Type string
+	// ID uniquely identifies the entry. Load defaults it to the type, with a
+	// numeric suffix when the same type appears more than once.
+	ID string
+	// Title overrides the section heading in the digest.
+	Title string
+	// Filters apply to this source's items only.
+	Filters FilterRules
+	// Fetch is the source's timeout and retry policy. Load fills in whatever
+	// the entry leaves unset from the top-level fetch block.
+	Fetch FetchConfig
+	// Line is the line of the entry in the config file, for error messages.
+	Line int
+
+	options yaml.Node
+}
+
+func (s *SourceConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return errorAt(node.Line, "source entry must be a mapping")
+	}
+
+	opts := yaml.Node{Kind: yaml.MappingNode, Tag: node.Tag, Line: node.Line, Column: node.Column}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		var err error
+		switch key.Value {
+		case "type":
+			err = val.Decode(&s.Type)
+		case "id":
+			err = val.Decode(&s.ID)
+		case "title":
+			err = val.Decode(&s.Title)
+		case "filters":
+			err = val.Decode(&s.Filters)
+		case "timeout":
+			err = val.Decode(&s.Fetch.Timeout)
+		case "retry":
+			err = val.Decode(&s.Fetch.Retry)
+		default:
+			opts.Content = append(opts.Content, key, val)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if s.Type == "" {
+		return errorAt(node.Line, "source entry has no type")
+	}
+
+	s.Line = node.Line
+	s.options = opts
+	return nil
+}
+
+// secretKeyPattern matches option keys that hold credentials, like api_token
+// or client_secret.
+var secretKeyPattern = regexp.MustCompile(`(?i)token|secret|password|key`)
+
+// Secrets returns the credentials in the config, so they can be kept out of
+// logs: the email secrets and every source option whose key names one.
+func (c *Config) Secrets() []string {
+	secrets := []string{c.Email.ResendAPIKey, c.Email.SMTP.Password}
+	for _, src := range c.Sources {
+		opts := src.options.Content
+		for i := 0; i+1 < len(opts); i += 2 {
+			key, val := opts[i], opts[i+1]
+			if val.Kind == yaml.ScalarNode && val.Tag == "!!str" && secretKeyPattern.MatchString(key.Value) {
+				secrets = append(secrets, val.Value)
+			}
+		}
+	}
+	return secrets
+}
+
+// Decode decodes the source's options block into v. Keys v has no field for
+// are an error.
+func (s SourceConfig) Decode(v any) error {
+	if s.options.Kind == 0 {
+		return nil
+	}
+	if errs := unknownKeys(&s.options, reflect.TypeOf(v), s.Type+" option"); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return s.options.Decode(v)
 }
 
 var envVarPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
@@ -60,61 +374,147 @@ func expandEnvVars(data []byte) []byte {
 	})
 }
 
-// IncrementEdition bumps the edition counter and writes it back to the config file.
-func IncrementEdition(path string) error {
+// Load reads and checks the config file. Unknown keys, unset environment
+// variables and invalid values are all reported, joined into one error. If the
+// file parses but has such problems, Load returns the config along with the
+// error, so callers like `burrow validate` can check the rest of it.
+func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("reading config file: %w", err)
-	}
-
-	// Simple find-and-replace on the raw YAML to avoid rewriting env vars like ${RESEND_API_KEY}
-	lines := strings.Split(string(data), "\n")
-	found := false
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "edition:") {
-			// Parse current value, increment, replace line
-			var current int
-			fmt.Sscanf(trimmed, "edition: %d", &current)
-			lines[i] = fmt.Sprintf("edition: %d", current+1)
-			found = true
-			break
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	data = expandEnvVars(data)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	var cfg Config
+	var errs []error
+	if len(doc.Content) > 0 {
+		cfg.root = doc.Content[0]
+		errs = append(errs, unresolvedVars(cfg.root)...)
+		errs = append(errs, unknownKeys(cfg.root, reflect.TypeFor[Config](), "key")...)
+		if err := cfg.root.Decode(&cfg); err != nil {
+			return nil, errors.Join(append(errs, fmt.Errorf("parsing config file: %w", err))...)
 		}
 	}
-	if !found {
-		// Insert edition field after the schedule line (or at top)
-		inserted := false
-		for i, line := range lines {
-			if strings.HasPrefix(strings.TrimSpace(line), "schedule:") {
-				newLines := make([]string, 0, len(lines)+1)
-				newLines = append(newLines, lines[:i+1]...)
-				newLines = append(newLines, "edition: 1")
-				newLines = append(newLines, lines[i+1:]...)
-				lines = newLines
-				inserted = true
-				break
-			}
+
+	if cfg.DataDir == "" {
+		cfg.DataDir = DefaultDataDir
+	}
+
+	errs = append(errs,
+		assignSourceIDs(cfg.Sources),
+		resolveRecipients(&cfg),
+		resolveFetch(&cfg),
+		resolveAlerts(&cfg),
+	)
+	errs = append(errs, cfg.check()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return &cfg, err
+	}
+	return &cfg, nil
+}
+
+// resolveRecipients migrates the legacy single `to` address, fills in default
+// schedules and checks that recipients only reference existing sources.
+func resolveRecipients(cfg *Config) error {
+	if len(cfg.Email.Recipients) == 0 && cfg.Email.To != "" {
+		cfg.Email.Recipients = []Recipient{{Address: cfg.Email.To}}
+	}
+
+	ids := make(map[string]bool, len(cfg.Sources))
+	for _, src := range cfg.Sources {
+		ids[src.ID] = true
+	}
+
+	var errs []error
+	for i := range cfg.Email.Recipients {
+		r := &cfg.Email.Recipients[i]
+		line := cfg.Line("email", "recipients", strconv.Itoa(i))
+		if r.Address == "" {
+			errs = append(errs, errorAt(line, "recipient #%d has no address", i+1))
+		}
+		if r.Schedule == "" {
+			r.Schedule = cfg.Schedule
 		}
-		if !inserted {
-			lines = append([]string{"edition: 1"}, lines...)
+		for _, id := range append(append([]string(nil), r.Sources...), r.Order...) {
+			if !ids[id] {
+				errs = append(errs, errorAt(line, "recipient %s: unknown source id %q", r.Address, id))
+			}
 		}
 	}
-
-	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	return errors.Join(errs...)
 }
 
-func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading config file: %w", err)
+// resolveAlerts fills in the alert defaults.
+func resolveAlerts(cfg *Config) error {
+	a := &cfg.Alerts
+	if a.After < 0 {
+		return errorAt(cfg.Line("alerts", "after"), "alerts.after must not be negative")
 	}
+	switch a.Delivery {
+	case "":
+		a.Delivery = AlertEmail
+	case AlertEmail, AlertDigest:
+	default:
+		return errorAt(cfg.Line("alerts", "delivery"), "unknown alerts.delivery %q (want %s or %s)", a.Delivery, AlertEmail, AlertDigest)
+	}
+	if a.To == "" && a.Delivery == AlertEmail && len(cfg.Email.Recipients) > 0 {
+		a.To = cfg.Email.Recipients[0].Address
+	}
+	return nil
+}
 
-	data = expandEnvVars(data)
+// resolveFetch completes the fetch policy of every source from the top-level
+// block and the defaults.
+func resolveFetch(cfg *Config) error {
+	cfg.Fetch = cfg.Fetch.withDefaults(defaultFetch)
+	if err := cfg.Fetch.validate(); err != nil {
+		return errorAt(cfg.Line("fetch"), "fetch: %v", err)
+	}
+	var errs []error
+	for i := range cfg.Sources {
+		src := &cfg.Sources[i]
+		src.Fetch = src.Fetch.withDefaults(cfg.Fetch)
+		if err := src.Fetch.validate(); err != nil {
+			errs = append(errs, errorAt(src.Line, "source %q: %v", src.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing config file: %w", err)
+// assignSourceIDs fills in missing source IDs and rejects duplicates.
+func assignSourceIDs(sources []SourceConfig) error {
+	taken := make(map[string]int)
+	for _, src := range sources {
+		if src.ID == "" {
+			continue
+		}
+		if line, dup := taken[src.ID]; dup {
+			return errorAt(src.Line, "source id %q already used on line %d", src.ID, line)
+		}
+		taken[src.ID] = src.Line
 	}
 
-	return &cfg, nil
+	for i := range sources {
+		src := &sources[i]
+		if src.ID != "" {
+			continue
+		}
+		id := src.Type
+		for n := 2; ; n++ {
+			if _, dup := taken[id]; !dup {
+				break
+			}
+			id = fmt.Sprintf("%s-%d", src.Type, n)
+		}
+		src.ID = id
+		taken[id] = src.Line
+	}
+	return nil
 }
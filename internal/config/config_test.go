@@ -1,9 +1,14 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -49,14 +54,20 @@ sources:
 	if redditSource == nil {
 		t.Fatal("expected a reddit source")
 	}
-	if len(redditSource.Subreddits) != 2 {
-		t.Fatalf("expected 2 subreddits, got %d", len(redditSource.Subreddits))
+	var opts struct {
+		Subreddits []string `yaml:"subreddits"`
 	}
-	if redditSource.Subreddits[0] != "de" {
-		t.Errorf("expected first subreddit 'de', got %q", redditSource.Subreddits[0])
+	if err := redditSource.Decode(&opts); err != nil {
+		t.Fatalf("decoding reddit options: %v", err)
 	}
-	if redditSource.Subreddits[1] != "golang" {
-		t.Errorf("expected second subreddit 'golang', got %q", redditSource.Subreddits[1])
+	if len(opts.Subreddits) != 2 {
+		t.Fatalf("expected 2 subreddits, got %d", len(opts.Subreddits))
+	}
+	if opts.Subreddits[0] != "de" {
+		t.Errorf("expected first subreddit 'de', got %q", opts.Subreddits[0])
+	}
+	if opts.Subreddits[1] != "golang" {
+		t.Errorf("expected second subreddit 'golang', got %q", opts.Subreddits[1])
 	}
 }
 
@@ -90,8 +101,14 @@ sources:
 	if redditSource == nil {
 		t.Fatal("expected a reddit source")
 	}
-	if redditSource.Subreddit != "de" {
-		t.Errorf("expected subreddit 'de', got %q", redditSource.Subreddit)
+	var opts struct {
+		Subreddit string `yaml:"subreddit"`
+	}
+	if err := redditSource.Decode(&opts); err != nil {
+		t.Fatalf("decoding reddit options: %v", err)
+	}
+	if opts.Subreddit != "de" {
+		t.Errorf("expected subreddit 'de', got %q", opts.Subreddit)
 	}
 }
 
@@ -127,7 +144,433 @@ sources:
 	if readwiseSource == nil {
 		t.Fatal("expected a readwise source")
 	}
-	if readwiseSource.APIToken != "secret-123" {
-		t.Errorf("expected token 'secret-123', got %q", readwiseSource.APIToken)
+	var opts struct {
+		APIToken string `yaml:"api_token"`
+	}
+	if err := readwiseSource.Decode(&opts); err != nil {
+		t.Fatalf("decoding readwise options: %v", err)
+	}
+	if opts.APIToken != "secret-123" {
+		t.Errorf("expected token 'secret-123', got %q", opts.APIToken)
+	}
+}
+
+func TestLoadSourceWithoutType(t *testing.T) {
+	content := `
+schedule: "0 7 * * *"
+sources:
+  - latitude: 52.52
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected error for source entry without type")
+	}
+	if !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("expected error to name line 4, got %v", err)
+	}
+}
+
+func TestLoadSourceIDs(t *testing.T) {
+	content := `
+schedule: "0 7 * * *"
+sources:
+  - type: reddit
+    subreddits: [golang]
+  - type: reddit
+    id: germany
+    title: "Germany"
+    subreddits: [de]
+  - type: reddit
+    subreddits: [rust]
+  - type: hackernews
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"reddit", "germany", "reddit-2", "hackernews"}
+	for i, id := range want {
+		if cfg.Sources[i].ID != id {
+			t.Errorf("source %d: expected id %q, got %q", i, id, cfg.Sources[i].ID)
+		}
+	}
+	if cfg.Sources[1].Title != "Germany" {
+		t.Errorf("expected title 'Germany', got %q", cfg.Sources[1].Title)
+	}
+
+	var opts map[string]any
+	if err := cfg.Sources[1].Decode(&opts); err != nil {
+		t.Fatalf("decoding options: %v", err)
+	}
+	if _, ok := opts["title"]; ok {
+		t.Error("expected title to be stripped from the options block")
+	}
+}
+
+func TestLoadDuplicateSourceID(t *testing.T) {
+	content := `
+schedule: "0 7 * * *"
+sources:
+  - type: reddit
+    id: news
+    subreddits: [de]
+  - type: hackernews
+    id: news
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for duplicate source id")
+	}
+}
+
+func TestLoadRecipients(t *testing.T) {
+	content := `
+schedule: "0 7 * * *"
+email:
+  from: "burrow@localhost"
+  recipients:
+    - "me@localhost"
+    - address: "team@localhost"
+      sources: [hackernews, reddit]
+      order: [reddit]
+      schedule: "0 8 * * 1-5"
+sources:
+  - type: reddit
+    subreddits: [golang]
+  - type: hackernews
+  - type: weather
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recipients := cfg.Email.Recipients
+	if len(recipients) != 2 {
+		t.Fatalf("expected 2 recipients, got %d", len(recipients))
+	}
+	if recipients[0].Address != "me@localhost" || recipients[0].Schedule != "0 7 * * *" {
+		t.Errorf("unexpected first recipient: %+v", recipients[0])
+	}
+	if !recipients[0].Wants("weather") {
+		t.Error("expected recipient without sources to want every source")
+	}
+	if recipients[1].Schedule != "0 8 * * 1-5" {
+		t.Errorf("expected schedule override, got %q", recipients[1].Schedule)
+	}
+	if recipients[1].Wants("weather") || !recipients[1].Wants("reddit") {
+		t.Errorf("unexpected source selection: %v", recipients[1].Sources)
+	}
+}
+
+func TestLoadLegacyTo(t *testing.T) {
+	content := `
+schedule: "0 7 * * *"
+email:
+  to: "you@localhost"
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Email.Recipients) != 1 || cfg.Email.Recipients[0].Address != "you@localhost" {
+		t.Errorf("expected legacy to address as sole recipient, got %+v", cfg.Email.Recipients)
+	}
+}
+
+func TestLoadRecipientUnknownSource(t *testing.T) {
+	content := `
+schedule: "0 7 * * *"
+email:
+  recipients:
+    - address: "me@localhost"
+      sources: [hackernewz]
+sources:
+  - type: hackernews
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for unknown source id")
+	}
+}
+
+func TestLoadFilters(t *testing.T) {
+	content := `
+filters:
+  exclude: [crypto]
+  domains: [example.com]
+  show_filtered: true
+sources:
+  - type: reddit
+    subreddits: [de]
+    filters:
+      exclude_patterns: ["(?i)wahl"]
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.Filters.ShowFiltered || len(cfg.Filters.Exclude) != 1 || cfg.Filters.Domains[0] != "example.com" {
+		t.Errorf("unexpected global filters %+v", cfg.Filters)
+	}
+	src := cfg.Sources[0]
+	if len(src.Filters.ExcludePatterns) != 1 {
+		t.Errorf("unexpected source filters %+v", src.Filters)
+	}
+
+	var opts struct {
+		Subreddits []string `yaml:"subreddits"`
+		Filters    any      `yaml:"filters"`
+	}
+	if err := src.Decode(&opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Filters != nil {
+		t.Error("expected filters to be kept out of the source options")
+	}
+}
+
+func TestLoadFetchPolicy(t *testing.T) {
+	content := `
+fetch:
+  timeout: 1m
+  retry:
+    attempts: 4
+sources:
+  - type: hackernews
+  - type: nitter
+    timeout: 10s
+    retry:
+      attempts: 2
+      retry_on: [5xx]
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hn := cfg.Sources[0].Fetch
+	if hn.Timeout != time.Minute || hn.Retry.Attempts != 4 || hn.Retry.Backoff != defaultFetch.Retry.Backoff || len(hn.Retry.RetryOn) != 4 {
+		t.Errorf("expected the global policy with defaults, got %+v", hn)
+	}
+	nitter := cfg.Sources[1].Fetch
+	if nitter.Timeout != 10*time.Second || nitter.Retry.Attempts != 2 || !slices.Equal(nitter.Retry.RetryOn, []string{RetryServerError}) {
+		t.Errorf("expected the source's own policy, got %+v", nitter)
+	}
+	if nitter.Retry.MaxBackoff != defaultFetch.Retry.MaxBackoff {
+		t.Errorf("expected max_backoff to fall back to the default, got %s", nitter.Retry.MaxBackoff)
+	}
+}
+
+func TestLoadFetchPolicyUnknownClass(t *testing.T) {
+	content := `
+sources:
+  - type: hackernews
+    retry:
+      retry_on: [4xx]
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `"4xx"`) {
+		t.Fatalf("expected an unknown retry_on error, got %v", err)
+	}
+}
+
+func TestLoadAlerts(t *testing.T) {
+	content := `
+email:
+  recipients: [me@example.com, you@example.com]
+alerts:
+  after: 3
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Alerts.Delivery != AlertEmail || cfg.Alerts.To != "me@example.com" {
+		t.Errorf("expected alerts by email to the first recipient, got %+v", cfg.Alerts)
+	}
+
+	os.WriteFile(path, []byte(content+"  delivery: pager\n"), 0644)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "pager") {
+		t.Errorf("expected an unknown delivery error, got %v", err)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	content := `
+email:
+  resend_api_key: re_123456789
+  recipients: [me@example.com]
+sources:
+  - type: readwise
+    token: rw-secret-token
+  - type: reddit
+    subreddits: [golang]
+    client_secret: reddit-secret
+    limit: 10
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secrets := strings.Join(cfg.Secrets(), ",")
+	for _, want := range []string{"re_123456789", "rw-secret-token", "reddit-secret"} {
+		if !strings.Contains(secrets, want) {
+			t.Errorf("expected %q among the secrets, got %q", want, secrets)
+		}
+	}
+	if strings.Contains(secrets, "golang") {
+		t.Errorf("expected only secret options, got %q", secrets)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	content := `
+schedul: "0 7 * * *"
+email:
+  from: burrow@example.com
+  recipients:
+    - address: not-an-address
+      schedule: "every morning"
+  resend_api_key: ${BURROW_TEST_UNSET_KEY}
+fetch:
+  retry:
+    attemps: 2
+sources:
+  - type: reddit
+    subredits: [golang]
+    filters:
+      exclud: [crypto]
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	if cfg == nil {
+		t.Fatal("expected the config along with the errors")
+	}
+	for _, want := range []string{
+		`line 2: unknown key "schedul" (did you mean "schedule"?)`,
+		`line 6: invalid email address "not-an-address"`,
+		`line 7: recipient not-an-address: invalid schedule "every morning"`,
+		`line 8: environment variable BURROW_TEST_UNSET_KEY is not set`,
+		`line 11: unknown key "attemps" (did you mean "attempts"?)`,
+		`line 16: unknown key "exclud" (did you mean "exclude"?)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+
+	// Options belong to the fetcher and are checked when decoded.
+	var opts struct {
+		Subreddits []string `yaml:"subreddits"`
+	}
+	err = cfg.Sources[0].Decode(&opts)
+	if err == nil || !strings.Contains(err.Error(), `line 14: unknown reddit option "subredits" (did you mean "subreddits"?)`) {
+		t.Errorf("expected an unknown option error, got %v", err)
+	}
+	if line := cfg.Sources[0].OptionLine("subredits"); line != 14 {
+		t.Errorf("expected the option on line 14, got %d", line)
+	}
+	if line := cfg.Sources[0].OptionLine("count"); line != 13 {
+		t.Errorf("expected an unset option to point at the entry on line 13, got %d", line)
+	}
+}
+
+func TestLine(t *testing.T) {
+	content := `
+email:
+  recipients:
+    - me@example.com
+    - address: you@example.com
+      schedule: "0 8 * * *"
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(content), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		path []string
+		want int
+	}{
+		{[]string{"email"}, 2},
+		{[]string{"email", "recipients", "1", "schedule"}, 6},
+		{[]string{"email", "recipients", "7"}, 3},
+		{[]string{"alerts", "to"}, 2},
+	} {
+		if got := cfg.Line(tc.path...); got != tc.want {
+			t.Errorf("Line(%v) = %d, want %d", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	type weatherOptions struct {
+		Latitude float64 `yaml:"latitude"`
+	}
+	schema := Schema(map[string]reflect.Type{"weather": reflect.TypeFor[weatherOptions]()})
+
+	out, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`"schedule":{"type":"string"}`,
+		`"format":{"enum":["text","json"],"type":"string"}`,
+		`"retry_on":{"items":{"enum":["network","timeout","5xx","429"],"type":"string"},"type":"array"}`,
+		`"timeout":{"pattern":`,
+		`"recipients":{"items":{"anyOf":[{"type":"string"}`,
+		`"if":{"properties":{"type":{"const":"weather"}}}`,
+		`"latitude":{"type":"number"}`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %s in the schema", want)
+		}
 	}
 }
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// durationPattern matches the durations time.ParseDuration accepts.
+const durationPattern = `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+// enums lists the allowed values of string fields, by type and field name.
+var enums = map[string][]string{
+	"LogConfig.Format":     {"text", "json"},
+	"LogConfig.Level":      {"debug", "info", "warn", "error"},
+	"AlertConfig.Delivery": {AlertEmail, AlertDigest},
+	"EmailConfig.Provider": {"resend", "smtp"},
+	"SMTPConfig.TLS":       {"starttls", "implicit", "none"},
+	"SMTPConfig.Auth":      {"plain", "login"},
+	"RetryConfig.RetryOn":  retryClasses,
+}
+
+// Schema returns a JSON Schema of the config file, for editor completion and
+// checks. options maps every source type to the struct its options block
+// decodes into.
+func Schema(options map[string]reflect.Type) map[string]any {
+	s := typeSchema(reflect.TypeFor[Config]())
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "Burrow config"
+	s["properties"].(map[string]any)["sources"] = map[string]any{
+		"type":  "array",
+		"items": sourceSchema(options),
+	}
+	return s
+}
+
+// sourceSchema describes a source entry: the shared keys, plus the options of
+// whichever type the entry names.
+func sourceSchema(options map[string]reflect.Type) map[string]any {
+	types := make([]string, 0, len(options))
+	for typ := range options {
+		types = append(types, typ)
+	}
+	slices.Sort(types)
+
+	shared := func() map[string]any {
+		props := make(map[string]any, len(sourceKeys))
+		for key, t := range sourceKeys {
+			props[key] = typeSchema(t)
+		}
+		props["type"] = map[string]any{"enum": types}
+		return props
+	}
+
+	var byType []any
+	for _, typ := range types {
+		props := shared()
+		for key, prop := range typeSchema(options[typ])["properties"].(map[string]any) {
+			props[key] = prop
+		}
+		byType = append(byType, map[string]any{
+			"if":   map[string]any{"properties": map[string]any{"type": map[string]any{"const": typ}}},
+			"then": map[string]any{"properties": props, "additionalProperties": false},
+		})
+	}
+	return map[string]any{
+		"type":       "object",
+		"required":   []string{"type"},
+		"properties": shared(),
+		"allOf":      byType,
+	}
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range structSchema(f.Type)["properties"].(map[string]any) {
+				props[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		prop := typeSchema(f.Type)
+		if values, ok := enums[t.Name()+"."+f.Name]; ok {
+			if items, ok := prop["items"].(map[string]any); ok {
+				items["enum"] = values
+			} else {
+				prop["enum"] = values
+			}
+		}
+		props[name] = prop
+	}
+
+	s := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if _, ok := reflect.PointerTo(t).MethodByName("UnmarshalYAML"); ok {
+		// Types with their own UnmarshalYAML also take a plain string, like
+		// a recipient's bare address.
+		return map[string]any{"anyOf": []any{map[string]any{"type": "string"}, s}}
+	}
+	return s
+}
@@ -0,0 +1,30 @@
+// Package dedupe keeps stories out of the digest that its recipients already
+// got in one of the last few editions.
+package dedupe
+
+import "github.com/janiskrasemann/burrow/internal/fetcher"
+
+// History tells in which edition a source last delivered an item to a
+// recipient.
+type History interface {
+	DeliveredIn(recipient, sourceID, key string) (edition int, ok bool)
+}
+
+// Screens returns a per-source screen for the given edition that rejects items
+// the same source delivered, within the previous `editions` editions, to every
+// recipient audience returns for it. An item some of them have not had yet is
+// kept.
+func Screens(h History, edition, editions int, audience func(sourceID string) []string) func(src fetcher.Source) fetcher.Screen {
+	return func(src fetcher.Source) fetcher.Screen {
+		recipients := audience(src.ID)
+		return func(item fetcher.Item) bool {
+			for _, rcpt := range recipients {
+				last, ok := h.DeliveredIn(rcpt, src.ID, item.Key())
+				if !ok || edition-last > editions {
+					return true
+				}
+			}
+			return len(recipients) == 0
+		}
+	}
+}
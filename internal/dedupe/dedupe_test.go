@@ -0,0 +1,44 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+type history map[string]int
+
+func (h history) DeliveredIn(recipient, sourceID, key string) (int, bool) {
+	e, ok := h[recipient+" "+sourceID+" "+key]
+	return e, ok
+}
+
+func TestScreens(t *testing.T) {
+	h := history{
+		"me hackernews example.com/yesterday":  9,
+		"you hackernews example.com/yesterday": 9,
+		"me hackernews example.com/last-week":  4,
+		"you hackernews example.com/last-week": 4,
+		"me reddit example.com/elsewhere":      9,
+		"me hackernews example.com/only-me":    9,
+	}
+	audience := func(string) []string { return []string{"me", "you"} }
+	screen := Screens(h, 10, 3, audience)(fetcher.Source{ID: "hackernews"})
+
+	tests := []struct {
+		url  string
+		keep bool
+	}{
+		{"https://example.com/yesterday", false},
+		{"https://example.com/last-week", true},
+		{"https://example.com/elsewhere", true},
+		{"https://example.com/only-me", true},
+		{"https://example.com/new", true},
+	}
+
+	for _, tt := range tests {
+		if got := screen(fetcher.HNPost{URL: tt.url}); got != tt.keep {
+			t.Errorf("screen(%s) = %v, want %v", tt.url, got, tt.keep)
+		}
+	}
+}
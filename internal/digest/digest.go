@@ -0,0 +1,403 @@
+// Package digest runs one digest edition: fetch the sources once, then render
+// and send a personalised digest to each recipient.
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/aggregator"
+	"github.com/janiskrasemann/burrow/internal/cache"
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/dedupe"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/filter"
+	"github.com/janiskrasemann/burrow/internal/health"
+	"github.com/janiskrasemann/burrow/internal/logging"
+	"github.com/janiskrasemann/burrow/internal/mailer"
+	"github.com/janiskrasemann/burrow/internal/ranking"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+type Runner struct {
+	cfg   *config.Config
+	agg   *aggregator.Aggregator
+	rend  *renderer.Renderer
+	mail  mailer.Mailer
+	store *state.Store
+	// filters may be nil, which hides nothing; filter.New never returns nil.
+	filters *filter.Engine
+	// profile is nil without an interest profile.
+	profile *ranking.Profile
+	// cache is nil when the stale fallback is disabled.
+	cache *cache.Cache
+
+	// onRun is called with every finished run; nil if nobody is watching.
+	onRun func(state.Run)
+
+	// mu serialises runs, since the renderer and the edition counter are
+	// shared between recipient groups with different schedules. The groups
+	// of one day share an edition.
+	mu sync.Mutex
+}
+
+func New(cfg *config.Config, agg *aggregator.Aggregator, rend *renderer.Renderer, mail mailer.Mailer, store *state.Store, filters *filter.Engine, cache *cache.Cache) *Runner {
+	return &Runner{
+		cfg:     cfg,
+		agg:     agg,
+		rend:    rend,
+		mail:    mail,
+		store:   store,
+		filters: filters,
+		profile: ranking.New(cfg.Interests),
+		cache:   cache,
+	}
+}
+
+// OnRun registers f to be called with the record of every finished run.
+func (r *Runner) OnRun(f func(state.Run)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onRun = f
+}
+
+// Run produces the next edition for the given recipients. Sources are fetched
+// once for all of them; a failed delivery is logged and recorded without
+// affecting the others. It returns an error only if nobody got the digest.
+func (r *Runner) Run(ctx context.Context, recipients []config.Recipient) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients configured")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runID := logging.NewRunID()
+	ctx = logging.WithRunID(ctx, runID)
+	started := time.Now()
+	edition := r.store.EditionOn(started)
+	slog.InfoContext(ctx, "Starting digest generation", "edition", edition, "recipients", len(recipients))
+
+	run := state.Run{ID: runID, Started: started, Edition: edition}
+	defer func() {
+		run.Finished = time.Now()
+		if err := r.store.RecordRun(run); err != nil {
+			slog.ErrorContext(ctx, "Failed to record run", "error", err)
+		}
+		if r.onRun != nil {
+			r.onRun(run)
+		}
+	}()
+
+	d := r.Fetch(ctx, recipients, edition)
+	results := d.Results
+	if r.cache != nil {
+		r.saveCache(ctx, results)
+	}
+	run.FailedSources = failedSources(results)
+	run.Sources = sourceStats(results)
+	if len(d.Filtered) > 0 {
+		slog.InfoContext(ctx, "Filters hid candidates", "count", len(d.Filtered))
+	}
+
+	notices, err := health.Track(r.store, results, r.cfg.Alerts.After, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record source health", "error", err)
+	}
+	if r.cfg.Alerts.Delivery == config.AlertDigest {
+		for _, n := range notices {
+			d.AdminNotes = append(d.AdminNotes, n.Summary())
+		}
+	}
+
+	sent := 0
+	notified := false
+	for _, rcpt := range recipients {
+		run.Recipients = append(run.Recipients, rcpt.Address)
+
+		selected := ForRecipient(d, rcpt)
+		if to := r.cfg.Alerts.To; to != "" && to != rcpt.Address {
+			selected.AdminNotes = nil
+		}
+		if err := r.deliver(ctx, rcpt.Address, selected, &run.RenderTime); err != nil {
+			slog.ErrorContext(ctx, "Failed to deliver digest", "to", rcpt.Address, "error", err)
+			if run.FailedRecipients == nil {
+				run.FailedRecipients = make(map[string]string)
+			}
+			run.FailedRecipients[rcpt.Address] = err.Error()
+			continue
+		}
+		r.recordDelivered(ctx, rcpt.Address, selected.Results, edition)
+		notified = notified || len(selected.AdminNotes) > 0
+		sent++
+		slog.InfoContext(ctx, "Digest sent", "edition", edition, "to", rcpt.Address)
+	}
+
+	if r.cfg.Alerts.Delivery == config.AlertEmail && health.Changed(notices) {
+		notified = r.sendAlert(ctx, notices)
+	}
+	// Undelivered notices stay new, so the next run tries again.
+	if notified {
+		if err := health.Acknowledge(r.store, notices); err != nil {
+			slog.ErrorContext(ctx, "Failed to record delivered alerts", "error", err)
+		}
+	}
+
+	if sent == 0 {
+		err := fmt.Errorf("digest #%d could not be delivered to any of %d recipients", edition, len(recipients))
+		run.Error = err.Error()
+		return err
+	}
+	run.Sent = true
+
+	if err := r.store.SetEditionOn(edition, started); err != nil {
+		slog.ErrorContext(ctx, "Failed to update edition counter", "error", err)
+	}
+
+	return nil
+}
+
+// Fetch fetches every source at least one of the recipients wants, screened
+// for the given edition, and falls back to the cache for failed ones. It
+// never writes the cache; Run does. Nil recipients fetch every source.
+func (r *Runner) Fetch(ctx context.Context, recipients []config.Recipient, edition int) renderer.Digest {
+	agg := r.agg
+	if recipients != nil {
+		agg = agg.Subset(func(src fetcher.Source) bool {
+			return slices.ContainsFunc(recipients, func(rcpt config.Recipient) bool {
+				return rcpt.Wants(src.ID)
+			})
+		})
+	}
+	if r.profile != nil {
+		ctx = fetcher.WithRanker(ctx, r.profile)
+	}
+
+	var hidden filter.Log
+	results := agg.FetchAll(ctx, r.screens(recipients, edition, &hidden)...)
+	if r.cache != nil {
+		r.fallBack(ctx, results)
+	}
+	return renderer.Digest{
+		Edition:      edition,
+		Results:      results,
+		Filtered:     hidden.Items(),
+		ShowFiltered: r.cfg.Filters.ShowFiltered,
+	}
+}
+
+// Preview fetches every source and renders the next edition without sending
+// it or touching the state store.
+func (r *Runner) Preview(ctx context.Context) (renderer.Digest, *renderer.RenderedEmail, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx = logging.WithRunID(ctx, logging.NewRunID())
+	d := r.Fetch(ctx, nil, r.NextEdition())
+	email, err := r.rend.RenderDigest(d)
+	if err != nil {
+		return d, nil, fmt.Errorf("rendering digest: %w", err)
+	}
+	return d, email, nil
+}
+
+// SendTest renders the full next edition and sends it to the configured
+// test_to address with a "[TEST]" subject prefix. The edition counter and the
+// delivered items are left alone, so the real run still gets the same items.
+func (r *Runner) SendTest(ctx context.Context) error {
+	to := r.cfg.Email.TestTo
+	if to == "" {
+		return fmt.Errorf("email.test_to is not configured")
+	}
+
+	_, email, err := r.Preview(ctx)
+	if err != nil {
+		return err
+	}
+	email.Subject = "[TEST] " + email.Subject
+
+	if err := r.mail.Send(ctx, to, email); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Test digest sent", "to", to)
+	return nil
+}
+
+// NextEdition returns the number the next digest will carry: today's edition
+// if one already went out.
+func (r *Runner) NextEdition() int {
+	return r.store.EditionOn(time.Now())
+}
+
+// sendAlert emails the health notices to the alert address and reports
+// whether it went out.
+func (r *Runner) sendAlert(ctx context.Context, notices []health.Notice) bool {
+	to := r.cfg.Alerts.To
+	if to == "" {
+		slog.WarnContext(ctx, "Not sending alert: no alert address", "sources", len(notices))
+		return false
+	}
+	if err := r.mail.Send(ctx, to, health.Email(notices)); err != nil {
+		slog.ErrorContext(ctx, "Failed to send alert", "to", to, "error", err)
+		return false
+	}
+	slog.InfoContext(ctx, "Alert sent", "to", to)
+	return true
+}
+
+// deliver renders and sends the digest to one recipient, adding the time
+// spent rendering to renderTime.
+func (r *Runner) deliver(ctx context.Context, to string, d renderer.Digest, renderTime *time.Duration) error {
+	start := time.Now()
+	email, err := r.rend.RenderDigest(d)
+	*renderTime += time.Since(start)
+	if err != nil {
+		return fmt.Errorf("rendering digest: %w", err)
+	}
+	slog.DebugContext(ctx, "Rendered digest", "to", to, "duration", time.Since(start))
+	return r.mail.Send(ctx, to, email)
+}
+
+// screens returns the item screens to apply when fetching edition for the
+// recipients, or for every configured recipient if nil. Items the filters
+// hide are recorded in hidden.
+func (r *Runner) screens(recipients []config.Recipient, edition int, hidden *filter.Log) []aggregator.ScreenFunc {
+	if recipients == nil {
+		recipients = r.cfg.Email.Recipients
+	}
+	audience := func(sourceID string) []string {
+		var addrs []string
+		for _, rcpt := range recipients {
+			if rcpt.Wants(sourceID) {
+				addrs = append(addrs, rcpt.Address)
+			}
+		}
+		return addrs
+	}
+
+	var s []aggregator.ScreenFunc
+	if r.cfg.Dedupe.Editions > 0 {
+		s = append(s, dedupe.Screens(r.store, edition, r.cfg.Dedupe.Editions, audience))
+	}
+	if r.filters != nil {
+		s = append(s, r.filters.Screens(hidden))
+	}
+	return s
+}
+
+// fallBack replaces failed results with their cached copy, if there is a
+// recent enough one.
+func (r *Runner) fallBack(ctx context.Context, results []fetcher.Result) {
+	now := time.Now()
+	for i, res := range results {
+		if res.Error == nil {
+			continue
+		}
+		cached, err := r.cache.Fallback(res, r.cfg.Fallback.MaxAge, now)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to read cached result", "source", res.ID, "error", err)
+			continue
+		}
+		if !cached.CachedAt.IsZero() {
+			slog.InfoContext(ctx, "Showing cached result instead", "source", res.ID, "cached_at", cached.CachedAt)
+		}
+		results[i] = cached
+	}
+}
+
+// saveCache stores every freshly fetched result as its source's latest copy.
+func (r *Runner) saveCache(ctx context.Context, results []fetcher.Result) {
+	now := time.Now()
+	for _, res := range results {
+		if res.Error != nil || !res.CachedAt.IsZero() {
+			continue
+		}
+		if err := r.cache.Save(res, now); err != nil {
+			slog.WarnContext(ctx, "Failed to cache result", "source", res.ID, "error", err)
+		}
+	}
+}
+
+// recordDelivered remembers which items of the results a recipient got in
+// edition.
+func (r *Runner) recordDelivered(ctx context.Context, to string, results []fetcher.Result, edition int) {
+	for _, res := range results {
+		if res.Error != nil || !res.CachedAt.IsZero() {
+			continue
+		}
+		keys := fetcher.Keys(res.Data)
+		if len(keys) == 0 {
+			continue
+		}
+		if err := r.store.MarkDelivered(to, res.ID, keys, edition, r.cfg.Dedupe.Editions); err != nil {
+			slog.ErrorContext(ctx, "Failed to record delivered items", "source", res.ID, "error", err)
+		}
+	}
+}
+
+// ForRecipient returns the part of the digest the recipient subscribed to,
+// with the sources from the recipient's order first.
+func ForRecipient(d renderer.Digest, rcpt config.Recipient) renderer.Digest {
+	var selected []fetcher.Result
+	for _, res := range d.Results {
+		if rcpt.Wants(res.ID) {
+			selected = append(selected, res)
+		}
+	}
+
+	rank := func(id string) int {
+		if i := slices.Index(rcpt.Order, id); i >= 0 {
+			return i
+		}
+		return len(rcpt.Order)
+	}
+	slices.SortStableFunc(selected, func(a, b fetcher.Result) int {
+		return rank(a.ID) - rank(b.ID)
+	})
+
+	var filtered []filter.Hidden
+	for _, h := range d.Filtered {
+		if rcpt.Wants(h.Source) {
+			filtered = append(filtered, h)
+		}
+	}
+
+	d.Results = selected
+	d.Filtered = filtered
+	return d
+}
+
+// sourceStats describes the fetch of every source. Sources showing a cached
+// copy count as having no items.
+func sourceStats(results []fetcher.Result) map[string]state.SourceStats {
+	stats := make(map[string]state.SourceStats, len(results))
+	for _, res := range results {
+		st := state.SourceStats{Type: res.Type, Duration: res.Duration}
+		if res.Failed() == nil {
+			st.Items = fetcher.Count(res.Data)
+		}
+		stats[res.ID] = st
+	}
+	return stats
+}
+
+// failedSources maps the IDs of sources that failed to their error message.
+func failedSources(results []fetcher.Result) map[string]string {
+	var failed map[string]string
+	for _, r := range results {
+		err := r.Failed()
+		if err == nil {
+			continue
+		}
+		if failed == nil {
+			failed = make(map[string]string)
+		}
+		failed[r.ID] = err.Error()
+	}
+	return failed
+}
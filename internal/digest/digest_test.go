@@ -0,0 +1,456 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/aggregator"
+	"github.com/janiskrasemann/burrow/internal/cache"
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/filter"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+type stubFetcher struct {
+	data  any
+	calls int
+}
+
+func (s *stubFetcher) Name() string { return "stub" }
+
+func (s *stubFetcher) Fetch(ctx context.Context) (any, error) {
+	s.calls++
+	return s.data, nil
+}
+
+type stubMailer struct {
+	sent     map[string]string
+	subjects map[string]string
+	fail     map[string]bool
+}
+
+func (m *stubMailer) Send(ctx context.Context, to string, email *renderer.RenderedEmail) error {
+	if m.fail[to] {
+		return fmt.Errorf("mailbox full")
+	}
+	m.sent[to] = email.Text
+	if m.subjects != nil {
+		m.subjects[to] = email.Subject
+	}
+	return nil
+}
+
+func newRunner(t *testing.T, mail *stubMailer, sources ...fetcher.Source) (*Runner, *state.Store) {
+	t.Helper()
+	rend, err := renderer.New(
+		`{{range .Results}}{{.ID}} {{end}}`,
+		`{{range .Results}}{{.ID}} {{end}}`,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return New(&config.Config{}, aggregator.New(sources...), rend, mail, store, nil, nil), store
+}
+
+func source(id string, f fetcher.Fetcher) fetcher.Source {
+	return fetcher.Source{Type: "stub", ID: id, Title: id, Fetcher: f}
+}
+
+func TestRunPerRecipient(t *testing.T) {
+	a, b, c := &stubFetcher{data: 1}, &stubFetcher{data: 2}, &stubFetcher{data: 3}
+	mail := &stubMailer{sent: map[string]string{}}
+	runner, store := newRunner(t, mail, source("a", a), source("b", b), source("c", c))
+
+	err := runner.Run(context.Background(), []config.Recipient{
+		{Address: "all@example.com", Order: []string{"c"}},
+		{Address: "some@example.com", Sources: []string{"a", "b"}, Order: []string{"b"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.TrimSpace(mail.sent["all@example.com"]); got != "c a b" {
+		t.Errorf("unexpected digest for all@example.com: %q", got)
+	}
+	if got := strings.TrimSpace(mail.sent["some@example.com"]); got != "b a" {
+		t.Errorf("unexpected digest for some@example.com: %q", got)
+	}
+	if a.calls != 1 || b.calls != 1 || c.calls != 1 {
+		t.Errorf("expected every source to be fetched once, got %d/%d/%d", a.calls, b.calls, c.calls)
+	}
+	if store.Edition() != 1 {
+		t.Errorf("expected edition 1, got %d", store.Edition())
+	}
+}
+
+func TestRunSharesEditionWithinDay(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}}
+	runner, store := newRunner(t, mail, source("a", &stubFetcher{data: 1}))
+
+	for _, addr := range []string{"morning@example.com", "evening@example.com"} {
+		if err := runner.Run(context.Background(), []config.Recipient{{Address: addr}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if store.Edition() != 1 {
+		t.Errorf("expected both schedules of the day to share edition 1, got %d", store.Edition())
+	}
+	if runs := store.Runs(); runs[0].Edition != 1 || runs[1].Edition != 1 {
+		t.Errorf("unexpected run editions %+v", runs)
+	}
+}
+
+func TestRunSkipsUnwantedSources(t *testing.T) {
+	a, b := &stubFetcher{data: 1}, &stubFetcher{data: 2}
+	mail := &stubMailer{sent: map[string]string{}}
+	runner, _ := newRunner(t, mail, source("a", a), source("b", b))
+
+	if err := runner.Run(context.Background(), []config.Recipient{{Address: "x@example.com", Sources: []string{"a"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.calls != 0 {
+		t.Errorf("expected source b not to be fetched, got %d calls", b.calls)
+	}
+}
+
+func TestRunContinuesAfterFailedRecipient(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}, fail: map[string]bool{"bad@example.com": true}}
+	runner, store := newRunner(t, mail, source("a", &stubFetcher{data: 1}))
+
+	err := runner.Run(context.Background(), []config.Recipient{
+		{Address: "bad@example.com"},
+		{Address: "good@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := mail.sent["good@example.com"]; !ok {
+		t.Error("expected good@example.com to receive the digest")
+	}
+
+	runs := store.Runs()
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+	if !runs[0].Sent || runs[0].FailedRecipients["bad@example.com"] == "" {
+		t.Errorf("unexpected run record %+v", runs[0])
+	}
+}
+
+func TestRunAllRecipientsFail(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}, fail: map[string]bool{"bad@example.com": true}}
+	runner, store := newRunner(t, mail, source("a", &stubFetcher{data: 1}))
+
+	if err := runner.Run(context.Background(), []config.Recipient{{Address: "bad@example.com"}}); err == nil {
+		t.Fatal("expected error when no recipient got the digest")
+	}
+	if store.Edition() != 0 {
+		t.Errorf("expected edition not to advance, got %d", store.Edition())
+	}
+}
+
+func TestSendTest(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}, subjects: map[string]string{}}
+	runner, store := newRunner(t, mail, source("a", &stubFetcher{data: 1}))
+	runner.cfg.Email.TestTo = "test@example.com"
+	runner.cfg.Dedupe.Editions = 3
+
+	for range 2 {
+		if err := runner.SendTest(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if !strings.HasPrefix(mail.subjects["test@example.com"], "[TEST] Burrow Digest") {
+		t.Errorf("unexpected subject %q", mail.subjects["test@example.com"])
+	}
+	if store.Edition() != 0 {
+		t.Errorf("expected edition not to advance, got %d", store.Edition())
+	}
+	if len(store.Runs()) != 0 {
+		t.Errorf("expected test sends not to be recorded as runs")
+	}
+}
+
+func TestSendTestWithoutAddress(t *testing.T) {
+	runner, _ := newRunner(t, &stubMailer{sent: map[string]string{}})
+	if err := runner.SendTest(context.Background()); err == nil {
+		t.Fatal("expected error without test_to")
+	}
+}
+
+func TestWriteDryRun(t *testing.T) {
+	runner, store := newRunner(t, &stubMailer{sent: map[string]string{}},
+		source("a", &stubFetcher{data: []string{"x"}}))
+	d, email, err := runner.Preview(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.Results = append(d.Results, fetcher.Result{Type: "stub", ID: "b", Error: fmt.Errorf("boom")})
+
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := WriteDryRun(dir, d, email); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"digest.html", "digest.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "results.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var dump []struct {
+		ID    string   `json:"id"`
+		Data  []string `json:"data"`
+		Error string   `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &dump); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dump) != 2 || dump[0].Data[0] != "x" || dump[1].Error != "boom" {
+		t.Errorf("unexpected results dump %+v", dump)
+	}
+	if store.Edition() != 0 {
+		t.Errorf("expected edition not to advance, got %d", store.Edition())
+	}
+}
+
+// screeningFetcher returns the feed items its screens admit.
+type screeningFetcher struct {
+	items []fetcher.FeedItem
+}
+
+func (s *screeningFetcher) Name() string { return "screening" }
+
+func (s *screeningFetcher) Fetch(ctx context.Context) (any, error) {
+	var kept []fetcher.FeedItem
+	for _, it := range s.items {
+		if fetcher.Admit(ctx, it) {
+			kept = append(kept, it)
+		}
+	}
+	return kept, nil
+}
+
+func TestRunFiltersPerRecipient(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}}
+	news := &screeningFetcher{items: []fetcher.FeedItem{
+		{Title: "Crypto rally", Link: "https://a.org/1"},
+		{Title: "Local news", Link: "https://a.org/2"},
+	}}
+	runner, _ := newRunner(t, mail, source("news", news), source("a", &stubFetcher{data: 1}))
+	runner.rend, _ = renderer.New(`{{len .Filtered}}`, `{{len .Filtered}} filtered`)
+
+	engine, err := filter.New(config.FilterRules{Exclude: []string{"crypto"}}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	runner.filters = engine
+
+	err = runner.Run(context.Background(), []config.Recipient{
+		{Address: "all@example.com"},
+		{Address: "a@example.com", Sources: []string{"a"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mail.sent["all@example.com"]; got != "1 filtered" {
+		t.Errorf("unexpected footer for all@example.com: %q", got)
+	}
+	if got := mail.sent["a@example.com"]; got != "0 filtered" {
+		t.Errorf("expected no filtered items from unsubscribed sources, got %q", got)
+	}
+}
+
+func TestRunDedupesPerRecipient(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}}
+	news := &screeningFetcher{items: []fetcher.FeedItem{{Title: "Story", Link: "https://a.org/1"}}}
+	runner, _ := newRunner(t, mail, source("news", news))
+	runner.rend, _ = renderer.New(`-`, `{{range .Results}}{{len (feedItems .Data)}}{{end}}`)
+	runner.cfg.Dedupe.Editions = 3
+	morning := []config.Recipient{{Address: "morning@example.com"}}
+	evening := []config.Recipient{{Address: "evening@example.com"}}
+
+	for _, group := range [][]config.Recipient{morning, evening, morning} {
+		if err := runner.Run(context.Background(), group); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if group[0].Address == "evening@example.com" && mail.sent["evening@example.com"] != "1" {
+			t.Errorf("expected the evening group to get the story only the morning group had, got %q", mail.sent["evening@example.com"])
+		}
+	}
+
+	if got := mail.sent["morning@example.com"]; got != "0" {
+		t.Errorf("expected the morning group not to get the story twice, got %q", got)
+	}
+}
+
+type failingFetcher struct {
+	data any
+	fail bool
+}
+
+func (f *failingFetcher) Name() string { return "failing" }
+
+func (f *failingFetcher) Fetch(ctx context.Context) (any, error) {
+	if f.fail {
+		return nil, fmt.Errorf("HTTP 502")
+	}
+	return f.data, nil
+}
+
+func TestRunFallsBackToCache(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}}
+	weather := &failingFetcher{data: []fetcher.WeatherData{{Temperature: 12}}}
+	runner, store := newRunner(t, mail, fetcher.Source{Type: fetcher.TypeWeather, ID: "weather", Fetcher: weather})
+	runner.rend, _ = renderer.New(`-`, `{{range .Results}}{{range weatherData .Data}}{{.Temperature}}{{end}} cached={{not .CachedAt.IsZero}}{{end}}`)
+	runner.cfg.Fallback.MaxAge = 36 * time.Hour
+	runner.cache, _ = cache.Open(t.TempDir())
+	rcpts := []config.Recipient{{Address: "me@example.com"}}
+
+	if _, _, err := runner.Preview(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	weather.fail = true
+	if d, _, _ := runner.Preview(context.Background()); d.Results[0].Error == nil {
+		t.Error("expected a preview not to fill the cache")
+	}
+	weather.fail = false
+
+	if err := runner.Run(context.Background(), rcpts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	weather.fail = true
+	if err := runner.Run(context.Background(), rcpts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mail.sent["me@example.com"]; got != "12 cached=true" {
+		t.Errorf("expected the cached weather, got %q", got)
+	}
+	runs := store.Runs()
+	if _, ok := runs[len(runs)-1].FailedSources["weather"]; !ok {
+		t.Errorf("expected the failed fetch to be recorded, got %+v", runs[len(runs)-1])
+	}
+}
+
+func TestRunSendsAlertEmail(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}, subjects: map[string]string{}}
+	readwise := &failingFetcher{fail: true}
+	runner, _ := newRunner(t, mail, fetcher.Source{Type: fetcher.TypeReadwise, ID: "readwise", Title: "Readwise", Fetcher: readwise})
+	runner.cfg.Alerts = config.AlertConfig{After: 2, Delivery: config.AlertEmail, To: "admin@example.com"}
+	rcpts := []config.Recipient{{Address: "me@example.com"}}
+
+	for run := 1; run <= 3; run++ {
+		runner.Run(context.Background(), rcpts)
+		_, alerted := mail.sent["admin@example.com"]
+		if alerted != (run == 2) {
+			t.Errorf("run %d: expected an alert only when the threshold is reached, alerted=%v", run, alerted)
+		}
+		delete(mail.sent, "admin@example.com")
+	}
+
+	readwise.fail = false
+	runner.Run(context.Background(), rcpts)
+	if got := mail.subjects["admin@example.com"]; got != "Burrow: Readwise working again" {
+		t.Errorf("expected a recovery notice, got subject %q", got)
+	}
+}
+
+func TestRunRetriesFailedAlert(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}, subjects: map[string]string{}, fail: map[string]bool{"admin@example.com": true}}
+	readwise := &failingFetcher{fail: true}
+	runner, store := newRunner(t, mail, fetcher.Source{Type: fetcher.TypeReadwise, ID: "readwise", Title: "Readwise", Fetcher: readwise})
+	runner.cfg.Alerts = config.AlertConfig{After: 1, Delivery: config.AlertEmail, To: "admin@example.com"}
+	rcpts := []config.Recipient{{Address: "me@example.com"}}
+
+	runner.Run(context.Background(), rcpts)
+	if store.Health("readwise").Alerted {
+		t.Fatal("expected an alert that failed to send not to count as sent")
+	}
+
+	mail.fail = nil
+	runner.Run(context.Background(), rcpts)
+	if got := mail.subjects["admin@example.com"]; got != "Burrow: Readwise failing" {
+		t.Errorf("expected the alert to be sent on the next run, got subject %q", got)
+	}
+	if !store.Health("readwise").Alerted {
+		t.Error("expected the delivered alert to be recorded")
+	}
+}
+
+func TestRunShowsAdminNotes(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}}
+	runner, _ := newRunner(t, mail, fetcher.Source{Type: fetcher.TypeReadwise, ID: "readwise", Title: "Readwise", Fetcher: &failingFetcher{fail: true}})
+	runner.rend, _ = renderer.New(`-`, `{{range .AdminNotes}}{{.}}{{end}}`)
+	runner.cfg.Alerts = config.AlertConfig{After: 1, Delivery: config.AlertDigest, To: "admin@example.com"}
+
+	err := runner.Run(context.Background(), []config.Recipient{{Address: "admin@example.com"}, {Address: "me@example.com"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mail.sent["admin@example.com"]; !strings.HasPrefix(got, "Readwise has failed 1 run in a row") {
+		t.Errorf("expected admin notes for the admin, got %q", got)
+	}
+	if got := mail.sent["me@example.com"]; got != "" {
+		t.Errorf("expected no admin notes for other recipients, got %q", got)
+	}
+}
+
+func TestRunKeepsAdminNotesForTheAlertAddress(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}}
+	runner, store := newRunner(t, mail, fetcher.Source{Type: fetcher.TypeReadwise, ID: "readwise", Title: "Readwise", Fetcher: &failingFetcher{fail: true}})
+	runner.cfg.Alerts = config.AlertConfig{After: 1, Delivery: config.AlertDigest, To: "admin@example.com"}
+
+	if err := runner.Run(context.Background(), []config.Recipient{{Address: "me@example.com"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.Health("readwise").Alerted {
+		t.Error("expected the notice to stay pending while the alert address got no digest")
+	}
+
+	runner.Run(context.Background(), []config.Recipient{{Address: "admin@example.com"}})
+	if !store.Health("readwise").Alerted {
+		t.Error("expected the notice to be recorded once the alert address got it")
+	}
+}
+
+func TestRunReportsToOnRun(t *testing.T) {
+	mail := &stubMailer{sent: map[string]string{}}
+	runner, _ := newRunner(t, mail, source("a", &stubFetcher{data: []int{1, 2, 3}}))
+	var got []state.Run
+	runner.OnRun(func(run state.Run) { got = append(got, run) })
+
+	if err := runner.Run(context.Background(), []config.Recipient{{Address: "me@example.com"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 1 || !got[0].Sent {
+		t.Fatalf("expected one successful run, got %+v", got)
+	}
+	if st := got[0].Sources["a"]; st.Items != 3 || st.Type != "stub" {
+		t.Errorf("unexpected source stats %+v", st)
+	}
+	if got[0].RenderTime <= 0 {
+		t.Error("expected the render time to be recorded")
+	}
+}
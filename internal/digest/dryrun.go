@@ -0,0 +1,60 @@
+package digest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/renderer"
+)
+
+// dumpedResult is the JSON form of a fetcher.Result; errors don't marshal on
+// their own.
+type dumpedResult struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+	// CachedAt is set when Data is a cached copy standing in for a failed
+	// fetch.
+	CachedAt *time.Time `json:"cached_at,omitempty"`
+}
+
+// WriteDryRun writes the rendered digest as digest.html and digest.txt plus
+// the raw results as results.json into dir, creating it if needed.
+func WriteDryRun(dir string, d renderer.Digest, email *renderer.RenderedEmail) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating output dir: %w", err)
+	}
+
+	dump := make([]dumpedResult, 0, len(d.Results))
+	for _, r := range d.Results {
+		d := dumpedResult{Type: r.Type, ID: r.ID, Title: r.Title, Data: r.Data}
+		if err := r.Failed(); err != nil {
+			d.Error = err.Error()
+		}
+		if !r.CachedAt.IsZero() {
+			d.CachedAt = &r.CachedAt
+		}
+		dump = append(dump, d)
+	}
+	raw, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding results: %w", err)
+	}
+
+	files := map[string][]byte{
+		"digest.html":  []byte(email.HTML),
+		"digest.txt":   []byte(email.Text),
+		"results.json": raw,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+	return nil
+}
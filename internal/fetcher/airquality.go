@@ -0,0 +1,206 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Level rates a reading from low to extreme.
+type Level int
+
+const (
+	LevelLow Level = iota
+	LevelModerate
+	LevelHigh
+	LevelVeryHigh
+	LevelExtreme
+)
+
+var levelNames = []string{"low", "moderate", "high", "very high", "extreme"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+// parseLevel parses a level name like "high" or "very_high".
+func parseLevel(s string) (Level, bool) {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "_", " "))
+	for i, name := range levelNames {
+		if s == name {
+			return Level(i), true
+		}
+	}
+	return 0, false
+}
+
+// levelScale holds the lowest values rated moderate, high, very high and
+// extreme.
+type levelScale [4]float64
+
+func (s levelScale) level(v float64) Level {
+	l := LevelLow
+	for _, bound := range s {
+		if v < bound {
+			break
+		}
+		l++
+	}
+	return l
+}
+
+var (
+	// aqiScale follows the bands of the European AQI: good and fair are low,
+	// poor is high, very poor very high, and extremely poor extreme.
+	aqiScale = levelScale{40, 60, 80, 100}
+	// pm25Scale follows the EEA's PM2.5 bands in µg/m³.
+	pm25Scale = levelScale{20, 25, 50, 75}
+	// uvScale follows the WHO's UV index categories.
+	uvScale = levelScale{3, 6, 8, 11}
+	// pollenScale rates counts in grains/m³; pollen has no extreme level.
+	pollenScale = levelScale{10, 50, 100, math.Inf(1)}
+)
+
+// Reading is a measured or forecast value with its level.
+type Reading struct {
+	Value float64
+	Level Level
+}
+
+// Pollen is the day's highest count of one type of pollen.
+type Pollen struct {
+	// Name is the plant, like "Birch".
+	Name string
+	// Count is in grains/m³.
+	Count float64
+	Level Level
+}
+
+// AirQuality is the air quality, UV index and pollen at a location today.
+type AirQuality struct {
+	// AQI is the current European Air Quality Index, nil when the station
+	// reports none.
+	AQI *Reading
+	// PM25 is the current concentration of fine particles in µg/m³, nil when
+	// the station reports none.
+	PM25 *Reading
+	// UVIndex is the day's highest UV index.
+	UVIndex Reading
+	// Pollen lists the types of pollen in the air today. Open-Meteo only
+	// forecasts pollen in Europe.
+	Pollen []Pollen
+	// Warnings describe every reading at or above the source's warning
+	// level, like "UV index is very high (8)".
+	Warnings []string
+}
+
+// pollenTypes are the pollen Open-Meteo forecasts, as its variable names and
+// display names.
+var pollenTypes = []struct{ param, name string }{
+	{"alder_pollen", "Alder"},
+	{"birch_pollen", "Birch"},
+	{"grass_pollen", "Grass"},
+	{"mugwort_pollen", "Mugwort"},
+	{"olive_pollen", "Olive"},
+	{"ragweed_pollen", "Ragweed"},
+}
+
+type airQualityResponse struct {
+	Current struct {
+		AQI  *float64 `json:"european_aqi"`
+		PM25 *float64 `json:"pm2_5"`
+	} `json:"current"`
+	// Hourly maps variable names to their hourly values, null where there
+	// is no forecast.
+	Hourly map[string]json.RawMessage `json:"hourly"`
+}
+
+// hourlyMax returns the highest of a variable's hourly values, and false if
+// it has none.
+func (r *airQualityResponse) hourlyMax(param string) (float64, bool) {
+	var values []*float64
+	if raw, ok := r.Hourly[param]; !ok || json.Unmarshal(raw, &values) != nil {
+		return 0, false
+	}
+	highest, found := 0.0, false
+	for _, v := range values {
+		if v != nil && (!found || *v > highest) {
+			highest, found = *v, true
+		}
+	}
+	return highest, found
+}
+
+// fetchAirQuality fetches today's air quality at a location. warnAt is the
+// level from which readings are turned into warnings; LevelLow turns them
+// off.
+func (w *Weather) fetchAirQuality(ctx context.Context, loc WeatherLocation, timezone string, warnAt Level) (*AirQuality, error) {
+	params := []string{"uv_index"}
+	for _, p := range pollenTypes {
+		params = append(params, p.param)
+	}
+	reqURL := fmt.Sprintf(
+		w.airQualityURL+"?latitude=%.4f&longitude=%.4f"+
+			"&current=european_aqi,pm2_5&hourly=%s&timezone=%s&forecast_days=1",
+		loc.Latitude, loc.Longitude, strings.Join(params, ","), url.QueryEscape(timezone),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching air quality: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, "Open-Meteo air quality API returned status %d", resp.StatusCode)
+	}
+
+	var result airQualityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding air quality response: %w", err)
+	}
+
+	aq := &AirQuality{}
+	var warnings []string
+	warn := func(name string, r Reading, unit string) {
+		if warnAt > LevelLow && r.Level >= warnAt {
+			warnings = append(warnings, fmt.Sprintf("%s is %s (%.0f%s)", name, r.Level, r.Value, unit))
+		}
+	}
+
+	if v := result.Current.AQI; v != nil {
+		aq.AQI = &Reading{Value: *v, Level: aqiScale.level(*v)}
+		warn("Air quality index", *aq.AQI, "")
+	}
+	if v := result.Current.PM25; v != nil {
+		aq.PM25 = &Reading{Value: *v, Level: pm25Scale.level(*v)}
+		warn("PM2.5", *aq.PM25, " µg/m³")
+	}
+	if v, ok := result.hourlyMax("uv_index"); ok {
+		aq.UVIndex = Reading{Value: v, Level: uvScale.level(v)}
+		warn("UV index", aq.UVIndex, "")
+	}
+	for _, p := range pollenTypes {
+		count, ok := result.hourlyMax(p.param)
+		if !ok || count <= 0 {
+			continue
+		}
+		pollen := Pollen{Name: p.name, Count: count, Level: pollenScale.level(count)}
+		aq.Pollen = append(aq.Pollen, pollen)
+		warn(p.name+" pollen", Reading{Value: count, Level: pollen.Level}, " grains/m³")
+	}
+	aq.Warnings = warnings
+	return aq, nil
+}
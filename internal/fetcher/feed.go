@@ -0,0 +1,332 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type FeedItem struct {
+	Title     string
+	Link      string
+	Source    string
+	Published time.Time
+	Summary   string
+	Images    []string
+}
+
+type feedOptions struct {
+	Feeds    []FeedSpec    `yaml:"feeds"`
+	Lookback time.Duration `yaml:"lookback"`
+	Limit    int           `yaml:"limit"`
+}
+
+func (o feedOptions) validate() error {
+	if len(o.Feeds) == 0 {
+		return optionErrorf("feeds", "feed source needs at least one feed")
+	}
+	var errs []error
+	for i, f := range o.Feeds {
+		if f.URL == "" {
+			errs = append(errs, optionErrorf("feeds", "feed #%d has no url", i+1))
+		} else if err := checkURL(f.URL); err != nil {
+			errs = append(errs, optionErrorf("feeds", "feed #%d: %v", i+1, err))
+		}
+		if f.Lookback < 0 || f.Limit < 0 {
+			errs = append(errs, optionErrorf("feeds", "feed #%d: lookback and limit must not be negative", i+1))
+		}
+	}
+	if o.Lookback < 0 {
+		errs = append(errs, optionErrorf("lookback", "must not be negative"))
+	}
+	if o.Limit < 0 {
+		errs = append(errs, optionErrorf("limit", "must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func init() {
+	Register(TypeFeed, func(env Env, o feedOptions) (Fetcher, error) {
+		for i, f := range o.Feeds {
+			if f.Lookback == 0 {
+				o.Feeds[i].Lookback = o.Lookback
+			}
+			if f.Limit == 0 {
+				o.Feeds[i].Limit = o.Limit
+			}
+		}
+		return NewFeed(env.Client, o.Feeds), nil
+	})
+}
+
+// FeedSpec configures a single RSS or Atom feed. In YAML it can be given as a
+// plain URL or as a mapping with optional name, lookback and limit.
+type FeedSpec struct {
+	URL string `yaml:"url"`
+	// Name is shown as the item's source; defaults to the feed's own title.
+	Name string `yaml:"name"`
+	// Lookback skips items older than this; defaults to 24h.
+	Lookback time.Duration `yaml:"lookback"`
+	// Limit caps the number of items taken from this feed; defaults to 3.
+	Limit int `yaml:"limit"`
+}
+
+func (s *FeedSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&s.URL)
+	}
+	type plain FeedSpec
+	return node.Decode((*plain)(s))
+}
+
+type Feed struct {
+	client *http.Client
+	feeds  []FeedSpec
+}
+
+func NewFeed(client *http.Client, feeds []FeedSpec) *Feed {
+	specs := make([]FeedSpec, len(feeds))
+	for i, f := range feeds {
+		if f.Lookback <= 0 {
+			f.Lookback = 24 * time.Hour
+		}
+		if f.Limit <= 0 {
+			f.Limit = 3
+		}
+		specs[i] = f
+	}
+	return &Feed{client: client, feeds: specs}
+}
+
+func (f *Feed) Name() string { return "Feeds" }
+
+func (f *Feed) Fetch(ctx context.Context) (any, error) {
+	type feedResult struct {
+		items []FeedItem
+		err   error
+	}
+
+	var wg sync.WaitGroup
+	results := make([]feedResult, len(f.feeds))
+
+	for i, spec := range f.feeds {
+		wg.Add(1)
+		go func(idx int, spec FeedSpec) {
+			defer wg.Done()
+			items, err := f.fetchFeed(ctx, spec)
+			results[idx] = feedResult{items: items, err: err}
+		}(i, spec)
+	}
+
+	wg.Wait()
+
+	var all []FeedItem
+	var firstErr error
+	failed := 0
+	for i, res := range results {
+		if res.err != nil {
+			slog.WarnContext(ctx, "Failed to fetch feed", "url", f.feeds[i].URL, "error", res.err)
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			failed++
+			continue
+		}
+		all = append(all, res.items...)
+	}
+
+	if failed == len(f.feeds) {
+		return nil, firstErr
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Published.After(all[j].Published)
+	})
+
+	return all, nil
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// link returns the entry's alternate link, which is the one without a rel or
+// with rel="alternate".
+func (e atomEntry) link() string {
+	for _, l := range e.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(e.Links) > 0 {
+		return e.Links[0].Href
+	}
+	return ""
+}
+
+func (f *Feed) fetchFeed(ctx context.Context, spec FeedSpec) ([]FeedItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Burrow/1.0)")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml, text/xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, "HTTP %d from %s", resp.StatusCode, spec.URL)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := parseFeed(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing feed %s: %w", spec.URL, err)
+	}
+
+	// Items without a date can't be held to the lookback, so they would show
+	// up in every digest; they are left out.
+	cutoff := time.Now().Add(-spec.Lookback)
+	var selected []FeedItem
+	undated := 0
+	for _, item := range items {
+		if item.Published.IsZero() {
+			undated++
+			continue
+		}
+		if item.Published.Before(cutoff) {
+			continue
+		}
+		if spec.Name != "" {
+			item.Source = spec.Name
+		}
+		selected = append(selected, item)
+	}
+	if undated > 0 {
+		slog.DebugContext(ctx, "Skipped feed items without a date", "url", spec.URL, "count", undated)
+	}
+	selected = screen(ctx, selected)
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Published.After(selected[j].Published)
+	})
+	if len(selected) > spec.Limit {
+		selected = selected[:spec.Limit]
+	}
+
+	return selected, nil
+}
+
+// parseFeed parses an RSS 2.0 or Atom document, telling them apart by the
+// root element. Items are returned in document order with Source set to the
+// feed's title.
+func parseFeed(body []byte) ([]FeedItem, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.NewDecoder(bytes.NewReader(body)).Decode(&root); err != nil {
+		return nil, err
+	}
+
+	var items []FeedItem
+	switch root.XMLName.Local {
+	case "rss":
+		var rss rssDocument
+		if err := xml.Unmarshal(body, &rss); err != nil {
+			return nil, err
+		}
+		for _, it := range rss.Channel.Items {
+			items = append(items, FeedItem{
+				Title:     strings.TrimSpace(it.Title),
+				Link:      strings.TrimSpace(it.Link),
+				Source:    strings.TrimSpace(rss.Channel.Title),
+				Published: parseRSSDate(strings.TrimSpace(it.PubDate)),
+				Summary:   plainText(it.Description),
+				Images:    extractImages(it.Description),
+			})
+		}
+	case "feed":
+		var atom atomFeed
+		if err := xml.Unmarshal(body, &atom); err != nil {
+			return nil, err
+		}
+		for _, e := range atom.Entries {
+			content := e.Summary
+			if content == "" {
+				content = e.Content
+			}
+			published := parseAtomDate(e.Published)
+			if published.IsZero() {
+				published = parseAtomDate(e.Updated)
+			}
+			items = append(items, FeedItem{
+				Title:     strings.TrimSpace(e.Title),
+				Link:      e.link(),
+				Source:    strings.TrimSpace(atom.Title),
+				Published: published,
+				Summary:   plainText(content),
+				Images:    extractImages(content),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported feed format <%s>", root.XMLName.Local)
+	}
+
+	return items, nil
+}
+
+func parseAtomDate(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+var (
+	htmlTagRe    = regexp.MustCompile(`<[^>]*>`)
+	whitespaceRe = regexp.MustCompile(`\s+`)
+)
+
+// plainText strips markup from an HTML fragment for use as an excerpt.
+func plainText(s string) string {
+	s = htmlTagRe.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(s, " "))
+}
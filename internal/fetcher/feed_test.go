@@ -0,0 +1,116 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFeedFetchRSSAndAtom(t *testing.T) {
+	now := time.Now().UTC()
+	recent := now.Add(-2 * time.Hour)
+	older := now.Add(-5 * time.Hour)
+	stale := now.Add(-72 * time.Hour)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprintf(w, `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Go Blog</title>
+<item><title>Go 1.26 is out</title><link>https://go.dev/blog/go1.26</link><pubDate>%s</pubDate>
+<description><![CDATA[<p>Today we release <b>Go 1.26</b>. It has generics &amp; more.</p><img src="https://go.dev/img.png">]]></description></item>
+<item><title>Old news</title><link>https://go.dev/blog/old</link><pubDate>%s</pubDate></item>
+</channel></rss>`, recent.Format(time.RFC1123Z), stale.Format(time.RFC1123Z))
+		case "/atom":
+			w.Header().Set("Content-Type", "application/atom+xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Releases</title>
+<entry><title>v2.0.0</title><link rel="alternate" href="https://example.com/v2"/><updated>%s</updated><summary>Breaking changes.</summary></entry>
+<entry><title>v1.9.0</title><link href="https://example.com/v1.9"/><published>%s</published></entry>
+<entry><title>v1.8.0</title><link href="https://example.com/v1.8"/><published>%s</published></entry>
+</feed>`, older.Format(time.RFC3339), older.Add(-time.Minute).Format(time.RFC3339), older.Add(-2*time.Minute).Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	feed := NewFeed(server.Client(), []FeedSpec{
+		{URL: server.URL + "/rss"},
+		{URL: server.URL + "/atom", Name: "Example releases", Limit: 2},
+	})
+
+	result, err := feed.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	items, ok := result.([]FeedItem)
+	if !ok {
+		t.Fatal("result is not []FeedItem")
+	}
+
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %d: %+v", len(items), items)
+	}
+
+	first := items[0]
+	if first.Title != "Go 1.26 is out" || first.Source != "Go Blog" {
+		t.Errorf("unexpected first item: %+v", first)
+	}
+	if first.Summary != "Today we release Go 1.26 . It has generics & more." {
+		t.Errorf("unexpected summary: %q", first.Summary)
+	}
+	if len(first.Images) != 1 {
+		t.Errorf("expected 1 image, got %v", first.Images)
+	}
+
+	if items[1].Title != "v2.0.0" || items[1].Link != "https://example.com/v2" {
+		t.Errorf("unexpected atom item: %+v", items[1])
+	}
+	if items[1].Source != "Example releases" {
+		t.Errorf("expected configured source name, got %q", items[1].Source)
+	}
+	if items[2].Title != "v1.9.0" {
+		t.Errorf("expected per-feed limit to drop v1.8.0, got %q", items[2].Title)
+	}
+}
+
+func TestFeedAllFeedsFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	feed := NewFeed(server.Client(), []FeedSpec{{URL: server.URL + "/a"}, {URL: server.URL + "/b"}})
+	if _, err := feed.Fetch(context.Background()); err == nil {
+		t.Error("expected error when every feed fails")
+	}
+}
+
+func TestFeedSpecFromPlainURL(t *testing.T) {
+	f, err := Build(TypeFeed, Env{Client: http.DefaultClient}, yamlOptions(`
+lookback: 72h
+feeds:
+  - https://go.dev/blog/feed.atom
+  - url: https://example.com/rss
+    limit: 1
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	feed := f.(*Feed)
+	if feed.feeds[0].URL != "https://go.dev/blog/feed.atom" {
+		t.Errorf("unexpected url: %q", feed.feeds[0].URL)
+	}
+	if feed.feeds[0].Lookback != 72*time.Hour || feed.feeds[0].Limit != 3 {
+		t.Errorf("expected source defaults on first feed, got %+v", feed.feeds[0])
+	}
+	if feed.feeds[1].Limit != 1 {
+		t.Errorf("expected per-feed limit 1, got %d", feed.feeds[1].Limit)
+	}
+}
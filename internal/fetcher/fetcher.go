@@ -1,12 +1,75 @@
 package fetcher
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Source types as used in the `type:` key of a source entry.
+const (
+	TypeWeather    = "weather"
+	TypeReadwise   = "readwise"
+	TypeHackerNews = "hackernews"
+	TypeReddit     = "reddit"
+	TypeNitter     = "nitter"
+	TypeUnsplash   = "unsplash"
+	TypeFeed       = "feed"
+)
+
+// dataTypes maps each source type to the type of Data its fetcher returns.
+var dataTypes = map[string]reflect.Type{
+	TypeWeather:    reflect.TypeFor[[]WeatherData](),
+	TypeReadwise:   reflect.TypeFor[[]Highlight](),
+	TypeHackerNews: reflect.TypeFor[[]HNPost](),
+	TypeReddit:     reflect.TypeFor[[]RedditPost](),
+	TypeNitter:     reflect.TypeFor[[]NitterPost](),
+	TypeUnsplash:   reflect.TypeFor[*UnsplashImage](),
+	TypeFeed:       reflect.TypeFor[[]FeedItem](),
+}
+
+// DecodeData decodes the JSON encoding of a result's Data back into the type
+// the fetcher of the given source type returns.
+func DecodeData(typ string, raw []byte) (any, error) {
+	t, ok := dataTypes[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown source type %q", typ)
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal(raw, v.Interface()); err != nil {
+		return nil, fmt.Errorf("decoding %s data: %w", typ, err)
+	}
+	return v.Elem().Interface(), nil
+}
 
 // Result holds the output of a single fetcher.
 type Result struct {
-	Name  string
+	// Type is the source type the result came from, e.g. TypeReddit.
+	Type string
+	// ID uniquely identifies the source entry within the config.
+	ID string
+	// Title is the section heading shown in the digest.
+	Title string
 	Data  any
 	Error error
+	// CachedAt is set when the fetch failed and Data is the last successful
+	// result, cached at that time, instead. Error is then nil and FetchError
+	// holds the failure.
+	CachedAt   time.Time
+	FetchError error
+	// Duration is how long the fetch took, retries included.
+	Duration time.Duration
+}
+
+// Failed returns the error the source's fetch failed with, even if a cached
+// result stands in for it.
+func (r Result) Failed() error {
+	if r.FetchError != nil {
+		return r.FetchError
+	}
+	return r.Error
 }
 
 // Fetcher is the interface all content modules implement.
@@ -16,3 +79,19 @@ type Fetcher interface {
 	// Fetch retrieves content from the source.
 	Fetch(ctx context.Context) (any, error)
 }
+
+// Source is a configured fetcher together with its identity in the digest.
+type Source struct {
+	Type    string
+	ID      string
+	Title   string
+	Fetcher Fetcher
+}
+
+// Dependent is implemented by fetchers that build on the output of the other
+// sources, like Unsplash picking an image for the day's Readwise book. The
+// aggregator fetches them after everything else, calling Prepare first.
+type Dependent interface {
+	Fetcher
+	Prepare(results []Result)
+}
@@ -0,0 +1,191 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Place is a location name resolved to coordinates.
+type Place struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone,omitempty"`
+	// Region and Country tell places of the same name apart, like
+	// "Lower Saxony" and "DE".
+	Region  string `json:"region,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// Label names the place by name, region and country code, in a form that
+// resolves back to it.
+func (p Place) Label() string {
+	parts := []string{p.Name}
+	for _, s := range []string{p.Region, p.Country} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PlaceCache remembers resolved location names between runs.
+type PlaceCache interface {
+	Place(query string) (Place, bool)
+	SetPlace(query string, p Place) error
+}
+
+// AmbiguousError is returned for a location name that matches several places.
+type AmbiguousError struct {
+	Query      string
+	Candidates []Place
+}
+
+func (e *AmbiguousError) Error() string {
+	labels := make([]string, len(e.Candidates))
+	for i, c := range e.Candidates {
+		labels[i] = fmt.Sprintf("%q (%.2f, %.2f)", c.Label(), c.Latitude, c.Longitude)
+	}
+	return fmt.Sprintf("location %q is ambiguous, it matches %s", e.Query, strings.Join(labels, ", "))
+}
+
+// UnreachableError is returned when the geocoding API could not be reached or
+// answered with an error, so whether a name exists is still unknown.
+type UnreachableError struct {
+	Query string
+	Err   error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("geocoding %q: %v", e.Query, e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error { return e.Err }
+
+type geocodingResponse struct {
+	Results []struct {
+		Name        string  `json:"name"`
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		Timezone    string  `json:"timezone"`
+		CountryCode string  `json:"country_code"`
+		Country     string  `json:"country"`
+		Admin1      string  `json:"admin1"`
+		Admin2      string  `json:"admin2"`
+	} `json:"results"`
+}
+
+// Geocoder resolves location names like "Hameln, DE" through Open-Meteo's
+// geocoding API. Resolved names are kept in its cache, if it has one, so each
+// is looked up only once.
+type Geocoder struct {
+	client  *http.Client
+	cache   PlaceCache
+	baseURL string
+}
+
+// NewGeocoder returns a geocoder; cache may be nil.
+func NewGeocoder(client *http.Client, cache PlaceCache) *Geocoder {
+	return &Geocoder{
+		client:  client,
+		cache:   cache,
+		baseURL: "https://geocoding-api.open-meteo.com/v1/search",
+	}
+}
+
+// Resolve returns the place a location name stands for. The name may be
+// qualified by region and country, separated by commas; a name that still
+// matches several places yields an *AmbiguousError, and a failure to ask the
+// API an *UnreachableError.
+func (g *Geocoder) Resolve(ctx context.Context, query string) (Place, error) {
+	key := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if g.cache != nil {
+		if p, ok := g.cache.Place(key); ok {
+			return p, nil
+		}
+	}
+
+	parts := strings.Split(query, ",")
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return Place{}, fmt.Errorf("empty location name")
+	}
+	var qualifiers []string
+	for _, q := range parts[1:] {
+		if q = strings.TrimSpace(q); q != "" {
+			qualifiers = append(qualifiers, q)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		g.baseURL+"?count=20&language=en&format=json&name="+url.QueryEscape(name), nil)
+	if err != nil {
+		return Place{}, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return Place{}, &UnreachableError{Query: query, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Place{}, &UnreachableError{Query: query, Err: statusError(resp, "Open-Meteo geocoding API returned status %d", resp.StatusCode)}
+	}
+
+	var result geocodingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Place{}, fmt.Errorf("decoding geocoding response: %w", err)
+	}
+
+	var candidates []Place
+	for _, r := range result.Results {
+		fields := []string{r.CountryCode, r.Country, r.Admin1, r.Admin2}
+		if !strings.EqualFold(r.Name, name) || !matchesAll(qualifiers, fields) {
+			continue
+		}
+		candidates = append(candidates, Place{
+			Name:      r.Name,
+			Latitude:  r.Latitude,
+			Longitude: r.Longitude,
+			Timezone:  r.Timezone,
+			Region:    r.Admin1,
+			Country:   r.CountryCode,
+		})
+	}
+
+	switch len(candidates) {
+	case 0:
+		return Place{}, fmt.Errorf("location %q not found", query)
+	case 1:
+	default:
+		return Place{}, &AmbiguousError{Query: query, Candidates: candidates}
+	}
+	if g.cache != nil {
+		if err := g.cache.SetPlace(key, candidates[0]); err != nil {
+			return Place{}, fmt.Errorf("caching location %q: %w", query, err)
+		}
+	}
+	return candidates[0], nil
+}
+
+// matchesAll reports whether every qualifier equals one of the fields,
+// ignoring case.
+func matchesAll(qualifiers, fields []string) bool {
+	for _, q := range qualifiers {
+		found := false
+		for _, f := range fields {
+			if strings.EqualFold(q, f) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
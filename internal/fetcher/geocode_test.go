@@ -0,0 +1,88 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const geocodingJSON = `{"results": [
+	{"name": "Springfield", "latitude": 39.80, "longitude": -89.64, "timezone": "America/Chicago", "country_code": "US", "country": "United States", "admin1": "Illinois"},
+	{"name": "Springfield", "latitude": 37.22, "longitude": -93.30, "timezone": "America/Chicago", "country_code": "US", "country": "United States", "admin1": "Missouri"},
+	{"name": "Springfield Lakes", "latitude": -27.67, "longitude": 152.92, "timezone": "Australia/Brisbane", "country_code": "AU", "country": "Australia", "admin1": "Queensland"},
+	{"name": "Hameln", "latitude": 52.10, "longitude": 9.36, "timezone": "Europe/Berlin", "country_code": "DE", "country": "Germany", "admin1": "Lower Saxony"}
+]}`
+
+type memoryPlaces map[string]Place
+
+func (m memoryPlaces) Place(query string) (Place, bool) { p, ok := m[query]; return p, ok }
+
+func (m memoryPlaces) SetPlace(query string, p Place) error { m[query] = p; return nil }
+
+func geocodingServer(t *testing.T, lookups *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lookups++
+		w.Write([]byte(geocodingJSON))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGeocoderResolve(t *testing.T) {
+	var lookups int
+	server := geocodingServer(t, &lookups)
+	places := memoryPlaces{}
+	g := NewGeocoder(server.Client(), places)
+	g.baseURL = server.URL
+
+	for _, query := range []string{"Hameln, DE", "Springfield, Missouri", "springfield, illinois, us"} {
+		if _, err := g.Resolve(context.Background(), query); err != nil {
+			t.Errorf("%s: unexpected error: %v", query, err)
+		}
+	}
+	p, err := g.Resolve(context.Background(), "Hameln,  de")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Latitude != 52.10 || p.Timezone != "Europe/Berlin" || p.Label() != "Hameln, Lower Saxony, DE" {
+		t.Errorf("unexpected place %+v", p)
+	}
+	if lookups != 3 || len(places) != 3 {
+		t.Errorf("expected a cached name to be looked up once, got %d lookups and %d cached", lookups, len(places))
+	}
+
+	if _, err := g.Resolve(context.Background(), "Hameln, FR"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	g.baseURL = "http://127.0.0.1:0"
+	var unreachable *UnreachableError
+	if _, err := g.Resolve(context.Background(), "Berlin"); !errors.As(err, &unreachable) {
+		t.Errorf("expected an *UnreachableError, got %v", err)
+	}
+}
+
+func TestGeocoderResolveAmbiguous(t *testing.T) {
+	var lookups int
+	server := geocodingServer(t, &lookups)
+	g := NewGeocoder(server.Client(), nil)
+	g.baseURL = server.URL
+
+	_, err := g.Resolve(context.Background(), "Springfield")
+	var ambiguous *AmbiguousError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("expected an *AmbiguousError, got %v", err)
+	}
+	if len(ambiguous.Candidates) != 2 {
+		t.Errorf("expected the two exact matches, got %+v", ambiguous.Candidates)
+	}
+	for _, want := range []string{`"Springfield, Illinois, US" (39.80, -89.64)`, `"Springfield, Missouri, US"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s in %q", want, err)
+		}
+	}
+}
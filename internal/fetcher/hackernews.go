@@ -16,6 +16,16 @@ type HNPost struct {
 	ObjectID    string `json:"objectID"`
 	Author      string `json:"author"`
 	StoryText   string `json:"story_text"`
+	// Ranking is set when an interest profile is configured.
+	Ranking *Ranking `json:"ranking,omitempty"`
+}
+
+// score is the post's ranking score, or its points without a ranking.
+func (p HNPost) score() float64 {
+	if p.Ranking != nil {
+		return p.Ranking.Score
+	}
+	return float64(p.Points)
 }
 
 func (p HNPost) CommentsURL() string {
@@ -26,6 +36,14 @@ type hnResponse struct {
 	Hits []HNPost `json:"hits"`
 }
 
+type hackerNewsOptions struct{}
+
+func init() {
+	Register(TypeHackerNews, func(env Env, _ hackerNewsOptions) (Fetcher, error) {
+		return NewHackerNews(env.Client), nil
+	})
+}
+
 type HackerNews struct {
 	client  *http.Client
 	baseURL string
@@ -51,7 +69,7 @@ func (h *HackerNews) Fetch(ctx context.Context) (any, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HN API returned status %d", resp.StatusCode)
+		return nil, statusError(resp, "HN API returned status %d", resp.StatusCode)
 	}
 
 	var result hnResponse
@@ -59,9 +77,12 @@ func (h *HackerNews) Fetch(ctx context.Context) (any, error) {
 		return nil, fmt.Errorf("decoding HN response: %w", err)
 	}
 
-	posts := result.Hits
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Points > posts[j].Points
+	posts := screen(ctx, result.Hits)
+	for i := range posts {
+		posts[i].Ranking = rank(ctx, posts[i], posts[i].Points)
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].score() > posts[j].score()
 	})
 
 	if len(posts) > 5 {
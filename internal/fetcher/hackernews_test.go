@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -48,6 +49,47 @@ func TestHackerNewsFetch(t *testing.T) {
 	}
 }
 
+func TestHackerNewsFetchBackfillsScreenedPosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"hits": [
+				{"title": "Post A", "url": "https://a.com", "points": 100, "objectID": "1"},
+				{"title": "Post B", "url": "https://b.com", "points": 200, "objectID": "2"},
+				{"title": "Post C", "url": "https://c.com", "points": 150, "objectID": "3"},
+				{"title": "Post D", "url": "https://d.com", "points": 50, "objectID": "4"},
+				{"title": "Post E", "url": "https://e.com", "points": 300, "objectID": "5"},
+				{"title": "Post F", "url": "https://f.com", "points": 10, "objectID": "6"}
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	hn := NewHackerNews(server.Client())
+	hn.baseURL = server.URL
+
+	ctx := WithScreen(context.Background(), func(item Item) bool {
+		return item.Key() != "e.com"
+	})
+	result, err := hn.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	posts := result.([]HNPost)
+	if len(posts) != 5 {
+		t.Fatalf("expected 5 posts, got %d", len(posts))
+	}
+	for _, p := range posts {
+		if p.Title == "Post E" {
+			t.Error("expected screened post to be dropped")
+		}
+	}
+	if posts[4].Title != "Post F" {
+		t.Errorf("expected Post F to fill the freed slot, got %q", posts[4].Title)
+	}
+}
+
 func TestHackerNewsCommentsURL(t *testing.T) {
 	post := HNPost{ObjectID: "42"}
 	expected := "https://news.ycombinator.com/item?id=42"
@@ -55,3 +97,45 @@ func TestHackerNewsCommentsURL(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, got)
 	}
 }
+
+// titleRanker boosts items whose title contains a word.
+type titleRanker struct{ word string }
+
+func (r titleRanker) Rank(item Item, popularity int) Ranking {
+	score := float64(popularity)
+	if strings.Contains(item.Info().Title, r.word) {
+		score *= 100
+	}
+	return Ranking{Score: score, Explanation: "test"}
+}
+
+func TestHackerNewsFetchSelectsByRanking(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"hits": [
+			{"title": "Viral 1", "url": "https://a.org/1", "points": 900, "objectID": "1"},
+			{"title": "Viral 2", "url": "https://a.org/2", "points": 800, "objectID": "2"},
+			{"title": "Viral 3", "url": "https://a.org/3", "points": 700, "objectID": "3"},
+			{"title": "Viral 4", "url": "https://a.org/4", "points": 600, "objectID": "4"},
+			{"title": "Viral 5", "url": "https://a.org/5", "points": 500, "objectID": "5"},
+			{"title": "Writing a Rust allocator", "url": "https://b.org", "points": 40, "objectID": "6"}
+		]}`))
+	}))
+	defer server.Close()
+
+	hn := NewHackerNews(http.DefaultClient)
+	hn.baseURL = server.URL
+
+	ctx := WithRanker(context.Background(), titleRanker{word: "Rust"})
+	result, err := hn.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	posts := result.([]HNPost)
+	if len(posts) != 5 || posts[0].Title != "Writing a Rust allocator" {
+		t.Errorf("expected the ranked post to lead, got %d posts led by %q", len(posts), posts[0].Title)
+	}
+	if posts[0].Ranking == nil || posts[0].Ranking.Explanation != "test" {
+		t.Error("expected posts to carry their ranking")
+	}
+}
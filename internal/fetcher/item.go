@@ -0,0 +1,160 @@
+package fetcher
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Item is implemented by the list entries fetchers return, so an item can be
+// recognised again in later editions.
+type Item interface {
+	// Key identifies the item: its canonical URL where it links somewhere,
+	// otherwise an ID from the source.
+	Key() string
+	// Info describes the item in source-independent terms for filtering.
+	Info() ItemInfo
+}
+
+// ItemInfo is the common view of an item across sources.
+type ItemInfo struct {
+	Title  string
+	Text   string
+	URL    string
+	Author string
+}
+
+// Domain returns the host of the item's URL without a leading "www.".
+func (i ItemInfo) Domain() string {
+	u, err := url.Parse(i.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func (p HNPost) Key() string {
+	if p.URL != "" {
+		return CanonicalURL(p.URL)
+	}
+	return CanonicalURL(p.CommentsURL())
+}
+
+func (p RedditPost) Key() string {
+	if p.URL != "" {
+		return CanonicalURL(p.URL)
+	}
+	return CanonicalURL(p.FullPermalink())
+}
+
+func (p NitterPost) Key() string { return CanonicalURL(p.Link) }
+
+func (i FeedItem) Key() string { return CanonicalURL(i.Link) }
+
+func (h Highlight) Key() string {
+	sum := sha1.Sum([]byte(h.Text))
+	return "highlight:" + hex.EncodeToString(sum[:8])
+}
+
+func (p HNPost) Info() ItemInfo {
+	link := p.URL
+	if link == "" {
+		link = p.CommentsURL()
+	}
+	return ItemInfo{Title: p.Title, Text: p.StoryText, URL: link, Author: p.Author}
+}
+
+func (p RedditPost) Info() ItemInfo {
+	link := p.URL
+	if link == "" {
+		link = p.FullPermalink()
+	}
+	return ItemInfo{Title: p.Title, Text: p.Selftext, URL: link, Author: p.Author}
+}
+
+func (p NitterPost) Info() ItemInfo {
+	return ItemInfo{Text: p.Text, URL: p.Link, Author: p.Username}
+}
+
+func (i FeedItem) Info() ItemInfo {
+	return ItemInfo{Title: i.Title, Text: i.Summary, URL: i.Link}
+}
+
+func (h Highlight) Info() ItemInfo {
+	return ItemInfo{Title: h.BookTitle, Text: h.Text, URL: h.SourceURL, Author: h.BookAuthor}
+}
+
+// Keys returns the keys of all items in a result's data. Data that is not a
+// list of items yields nil.
+func Keys(data any) []string {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		return nil
+	}
+	var keys []string
+	for i := 0; i < v.Len(); i++ {
+		if item, ok := v.Index(i).Interface().(Item); ok {
+			keys = append(keys, item.Key())
+		}
+	}
+	return keys
+}
+
+// Count returns the number of items in a result's data: the length of a
+// list, or one for a single value like the weather.
+func Count(data any) int {
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Slice:
+		return v.Len()
+	case reflect.Pointer:
+		if v.IsNil() {
+			return 0
+		}
+	}
+	return 1
+}
+
+// CanonicalURL normalises a URL so the same story is recognised regardless of
+// scheme, a leading "www.", tracking parameters, fragments or a trailing slash.
+func CanonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+
+	query := u.Query()
+	for k := range query {
+		if strings.HasPrefix(k, "utm_") {
+			query.Del(k)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(host)
+	b.WriteString(path)
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(k))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(strings.Join(query[k], ",")))
+	}
+	return b.String()
+}
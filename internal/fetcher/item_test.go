@@ -0,0 +1,41 @@
+package fetcher
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://www.Example.com/post/", "example.com/post"},
+		{"http://example.com/post#comments", "example.com/post"},
+		{"https://example.com/post?utm_source=hn&id=3&a=1", "example.com/post?a=1&id=3"},
+		{"not a url", "not a url"},
+	}
+
+	for _, tt := range tests {
+		if got := CanonicalURL(tt.in); got != tt.want {
+			t.Errorf("CanonicalURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	posts := []HNPost{
+		{URL: "https://www.example.com/a/"},
+		{ObjectID: "42"},
+	}
+	keys := Keys(posts)
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %v", keys)
+	}
+	if keys[0] != "example.com/a" {
+		t.Errorf("expected canonical URL key, got %q", keys[0])
+	}
+	if keys[1] != "news.ycombinator.com/item?id=42" {
+		t.Errorf("expected comments URL key for ask post, got %q", keys[1])
+	}
+
+	if Keys(WeatherData{}) != nil {
+		t.Error("expected no keys for non-list data")
+	}
+}
@@ -3,9 +3,10 @@ package fetcher
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"sort"
@@ -24,6 +25,34 @@ type NitterPost struct {
 	IsReply   bool
 }
 
+type nitterOptions struct {
+	Instance  string   `yaml:"nitter_instance"`
+	Usernames []string `yaml:"usernames"`
+	Limit     int      `yaml:"limit"`
+}
+
+func (o nitterOptions) validate() error {
+	var errs []error
+	if o.Instance == "" {
+		errs = append(errs, optionErrorf("nitter_instance", "nitter source needs a nitter_instance"))
+	} else if err := checkURL(o.Instance); err != nil {
+		errs = append(errs, optionErrorf("nitter_instance", "%v", err))
+	}
+	if len(o.Usernames) == 0 {
+		errs = append(errs, optionErrorf("usernames", "nitter source needs at least one username"))
+	}
+	if o.Limit < 0 {
+		errs = append(errs, optionErrorf("limit", "must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func init() {
+	Register(TypeNitter, func(env Env, o nitterOptions) (Fetcher, error) {
+		return NewNitter(env.Client, o.Instance, o.Usernames, o.Limit), nil
+	})
+}
+
 type Nitter struct {
 	client    *http.Client
 	instance  string
@@ -47,10 +76,10 @@ func (n *Nitter) Fetch(ctx context.Context) (any, error) {
 	for _, username := range n.usernames {
 		posts, err := n.fetchUser(ctx, username, cutoff)
 		if err != nil {
-			log.Printf("nitter: failed to fetch @%s: %v", username, err)
+			slog.WarnContext(ctx, "Failed to fetch nitter user", "username", username, "error", err)
 			continue
 		}
-		allPosts = append(allPosts, posts...)
+		allPosts = append(allPosts, screen(ctx, posts)...)
 	}
 
 	sort.Slice(allPosts, func(i, j int) bool {
@@ -110,6 +139,7 @@ type rssDocument struct {
 }
 
 type rssChannel struct {
+	Title string    `xml:"title"`
 	Items []rssItem `xml:"item"`
 }
 
@@ -140,7 +170,7 @@ func (n *Nitter) fetchUser(ctx context.Context, username string, cutoff time.Tim
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, url)
+		return nil, statusError(resp, "HTTP %d from %s", resp.StatusCode, url)
 	}
 
 	body, err := io.ReadAll(resp.Body)
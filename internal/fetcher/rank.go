@@ -0,0 +1,34 @@
+package fetcher
+
+import "context"
+
+// A Ranker scores an item from its popularity on the source, e.g. HN points
+// or Reddit upvotes, and the reader's interests.
+type Ranker interface {
+	Rank(item Item, popularity int) Ranking
+}
+
+// Ranking is an item's score and how it came about.
+type Ranking struct {
+	Score       float64 `json:"score"`
+	Explanation string  `json:"explanation"`
+}
+
+type rankerKey struct{}
+
+// WithRanker returns a context under which fetchers that select by popularity
+// select by r's score instead.
+func WithRanker(ctx context.Context, r Ranker) context.Context {
+	return context.WithValue(ctx, rankerKey{}, r)
+}
+
+// rank scores item with the ranker on ctx. Without one it returns nil and
+// callers fall back to the raw popularity.
+func rank(ctx context.Context, item Item, popularity int) *Ranking {
+	r, ok := ctx.Value(rankerKey{}).(Ranker)
+	if !ok {
+		return nil
+	}
+	ranking := r.Rank(item, popularity)
+	return &ranking
+}
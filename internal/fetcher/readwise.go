@@ -26,6 +26,23 @@ type readwiseResponse struct {
 	} `json:"results"`
 }
 
+type readwiseOptions struct {
+	APIToken string `yaml:"api_token"`
+}
+
+func (o readwiseOptions) validate() error {
+	if o.APIToken == "" {
+		return optionErrorf("api_token", "readwise source needs an api_token")
+	}
+	return nil
+}
+
+func init() {
+	Register(TypeReadwise, func(env Env, o readwiseOptions) (Fetcher, error) {
+		return NewReadwise(env.Client, o.APIToken), nil
+	})
+}
+
 type Readwise struct {
 	client   *http.Client
 	apiToken string
@@ -58,7 +75,7 @@ func (r *Readwise) Fetch(ctx context.Context) (any, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Readwise API returned status %d", resp.StatusCode)
+		return nil, statusError(resp, "Readwise API returned status %d", resp.StatusCode)
 	}
 
 	var result readwiseResponse
@@ -66,15 +83,20 @@ func (r *Readwise) Fetch(ctx context.Context) (any, error) {
 		return nil, fmt.Errorf("decoding Readwise response: %w", err)
 	}
 
-	if len(result.Results) == 0 {
+	candidates := make([]Highlight, 0, len(result.Results))
+	for _, r := range result.Results {
+		candidates = append(candidates, Highlight{
+			Text:       r.Text,
+			BookTitle:  r.Book.Title,
+			BookAuthor: r.Book.Author,
+			SourceURL:  r.Book.SourceURL,
+		})
+	}
+	candidates = screen(ctx, candidates)
+
+	if len(candidates) == 0 {
 		return []Highlight{}, nil
 	}
 
-	pick := result.Results[rand.IntN(len(result.Results))]
-	return []Highlight{{
-		Text:       pick.Text,
-		BookTitle:  pick.Book.Title,
-		BookAuthor: pick.Book.Author,
-		SourceURL:  pick.Book.SourceURL,
-	}}, nil
+	return []Highlight{candidates[rand.IntN(len(candidates))]}, nil
 }
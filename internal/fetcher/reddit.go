@@ -3,10 +3,19 @@ package fetcher
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type RedditPost struct {
@@ -18,6 +27,16 @@ type RedditPost struct {
 	Author      string `json:"author"`
 	Selftext    string `json:"selftext"`
 	Subreddit   string `json:"subreddit"`
+	// Ranking is set when an interest profile is configured.
+	Ranking *Ranking `json:"ranking,omitempty"`
+}
+
+// score is the post's ranking score, or its upvotes without a ranking.
+func (p RedditPost) score() float64 {
+	if p.Ranking != nil {
+		return p.Ranking.Score
+	}
+	return float64(p.Score)
 }
 
 func (p RedditPost) FullPermalink() string {
@@ -32,13 +51,224 @@ type redditResponse struct {
 	} `json:"data"`
 }
 
+type redditOptions struct {
+	Subreddit    string          `yaml:"subreddit"`
+	Subreddits   []SubredditSpec `yaml:"subreddits"`
+	Listing      string          `yaml:"listing"`
+	Time         string          `yaml:"time"`
+	MinScore     int             `yaml:"min_score"`
+	Count        int             `yaml:"count"`
+	UserAgent    string          `yaml:"user_agent"`
+	ClientID     string          `yaml:"client_id"`
+	ClientSecret string          `yaml:"client_secret"`
+	Username     string          `yaml:"username"`
+	Password     string          `yaml:"password"`
+}
+
+func (o redditOptions) query() RedditQuery {
+	subs := o.Subreddits
+	if len(subs) == 0 && o.Subreddit != "" {
+		subs = []SubredditSpec{{Name: o.Subreddit}}
+	}
+	return RedditQuery{Subreddits: subs, Listing: o.Listing, Time: o.Time, MinScore: o.MinScore, Count: o.Count}
+}
+
+func (o redditOptions) validate() error {
+	query := o.query()
+	if len(query.Subreddits) == 0 {
+		return optionErrorf("subreddits", "reddit source needs at least one subreddit")
+	}
+	errs := []error{query.validate()}
+	if o.ClientID != "" || o.ClientSecret != "" {
+		if o.ClientID == "" || o.ClientSecret == "" {
+			errs = append(errs, optionErrorf("client_id", "reddit oauth needs both client_id and client_secret"))
+		}
+		if (o.Username == "") != (o.Password == "") {
+			errs = append(errs, optionErrorf("username", "reddit oauth needs both username and password, or neither"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func init() {
+	Register(TypeReddit, func(env Env, o redditOptions) (Fetcher, error) {
+		query := o.query()
+		var creds *RedditCredentials
+		if o.ClientID != "" {
+			creds = &RedditCredentials{
+				ClientID:     o.ClientID,
+				ClientSecret: o.ClientSecret,
+				Username:     o.Username,
+				Password:     o.Password,
+			}
+		}
+		return NewReddit(env.Client, query, o.UserAgent, creds), nil
+	})
+}
+
+var (
+	redditListings = []string{"top", "hot", "rising", "new"}
+	redditTimes    = []string{"hour", "day", "week", "month", "year", "all"}
+)
+
+// RedditQuery selects what a reddit source fetches and how many posts of each
+// subreddit end up in the digest.
+type RedditQuery struct {
+	Subreddits []SubredditSpec
+	// Listing is top (default), hot, rising or new.
+	Listing string
+	// Time is the window of the top listing: hour, day (default), week, month,
+	// year or all.
+	Time string
+	// MinScore drops posts with fewer upvotes.
+	MinScore int
+	// Count is the total number of posts; defaults to max(5, subreddits).
+	Count int
+}
+
+// SubredditSpec configures one subreddit. In YAML it can be given as a plain
+// name or as a mapping with optional time, weight and guaranteed.
+type SubredditSpec struct {
+	Name string `yaml:"name"`
+	// Time overrides the source's time window for this subreddit.
+	Time string `yaml:"time"`
+	// Weight multiplies the subreddit's scores when competing for the slots
+	// left after the guarantees; 1 if nil. 0 leaves the subreddit only its
+	// guaranteed posts.
+	Weight *float64 `yaml:"weight"`
+	// Guaranteed is how many of the subreddit's best posts are always
+	// included; 1 if nil.
+	Guaranteed *int `yaml:"guaranteed"`
+}
+
+func (s SubredditSpec) weight() float64 {
+	if s.Weight == nil {
+		return 1
+	}
+	return *s.Weight
+}
+
+func (s SubredditSpec) guaranteed() int {
+	if s.Guaranteed == nil {
+		return 1
+	}
+	return *s.Guaranteed
+}
+
+func (s *SubredditSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&s.Name)
+	}
+	type plain SubredditSpec
+	return node.Decode((*plain)(s))
+}
+
+// count returns the total number of posts, with the default if Count is
+// unset.
+func (q RedditQuery) count() int {
+	if q.Count <= 0 {
+		return max(5, len(q.Subreddits))
+	}
+	return q.Count
+}
+
+func (q RedditQuery) validate() error {
+	var errs []error
+	if q.Listing != "" && !slices.Contains(redditListings, q.Listing) {
+		errs = append(errs, optionErrorf("listing", "unknown reddit listing %q (want one of %s)", q.Listing, strings.Join(redditListings, ", ")))
+	}
+	if q.Time != "" && !slices.Contains(redditTimes, q.Time) {
+		errs = append(errs, optionErrorf("time", "unknown reddit time window %q (want one of %s)", q.Time, strings.Join(redditTimes, ", ")))
+	}
+	guaranteed := 0
+	for _, s := range q.Subreddits {
+		if s.Time != "" && !slices.Contains(redditTimes, s.Time) {
+			errs = append(errs, optionErrorf("subreddits", "r/%s: unknown reddit time window %q (want one of %s)", s.Name, s.Time, strings.Join(redditTimes, ", ")))
+		}
+		if s.Name == "" {
+			errs = append(errs, optionErrorf("subreddits", "reddit subreddit entry has no name"))
+		}
+		if s.weight() < 0 || s.guaranteed() < 0 {
+			errs = append(errs, optionErrorf("subreddits", "r/%s: weight and guaranteed must not be negative", s.Name))
+		}
+		guaranteed += max(0, s.guaranteed())
+	}
+	if q.Count < 0 {
+		errs = append(errs, optionErrorf("count", "reddit count must not be negative"))
+	}
+	if count := q.count(); q.Count >= 0 && guaranteed > count {
+		errs = append(errs, optionErrorf("subreddits", "subreddits guarantee %d posts in total, more than the count of %d", guaranteed, count))
+	}
+	return errors.Join(errs...)
+}
+
+const (
+	defaultRedditUserAgent = "burrow/1.0 (by /u/kaktus_jack; info@burrow.janiskrasemann.com)"
+	// maxRedditRateLimitWait caps how long a fetch waits for the rate limit
+	// window to reset before giving up.
+	maxRedditRateLimitWait = 30 * time.Second
+)
+
+// RedditCredentials authenticate as a Reddit "script" app. Without a username
+// and password the app-only client credentials grant is used.
+type RedditCredentials struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+}
+
 type Reddit struct {
-	subreddits []string
-	baseURL    string
+	client    *http.Client
+	query     RedditQuery
+	userAgent string
+	creds     *RedditCredentials
+	baseURL   string
+	tokenURL  string
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+
+	// limitMu guards the rate limit state from the last X-Ratelimit-* headers.
+	limitMu   sync.Mutex
+	remaining float64
+	resetAt   time.Time
 }
 
-func NewReddit(subreddits []string) *Reddit {
-	return &Reddit{subreddits: subreddits, baseURL: "https://www.reddit.com"}
+func NewReddit(client *http.Client, query RedditQuery, userAgent string, creds *RedditCredentials) *Reddit {
+	if userAgent == "" {
+		userAgent = defaultRedditUserAgent
+	}
+	if query.Listing == "" {
+		query.Listing = "top"
+	}
+	if query.Time == "" {
+		query.Time = "day"
+	}
+	query.Count = query.count()
+	subs := make([]SubredditSpec, len(query.Subreddits))
+	for i, s := range query.Subreddits {
+		if s.Time == "" {
+			s.Time = query.Time
+		}
+		subs[i] = s
+	}
+	query.Subreddits = subs
+
+	baseURL := "https://www.reddit.com"
+	if creds != nil {
+		baseURL = "https://oauth.reddit.com"
+	}
+	return &Reddit{
+		client:    client,
+		query:     query,
+		userAgent: userAgent,
+		creds:     creds,
+		baseURL:   baseURL,
+		tokenURL:  "https://www.reddit.com/api/v1/access_token",
+		remaining: -1,
+	}
 }
 
 func (r *Reddit) Name() string { return "Reddit" }
@@ -51,14 +281,14 @@ func (r *Reddit) Fetch(ctx context.Context) (any, error) {
 	}
 
 	var wg sync.WaitGroup
-	results := make([]subredditResult, len(r.subreddits))
+	results := make([]subredditResult, len(r.query.Subreddits))
 
-	for i, sub := range r.subreddits {
+	for i, sub := range r.query.Subreddits {
 		wg.Add(1)
-		go func(idx int, subreddit string) {
+		go func(idx int, spec SubredditSpec) {
 			defer wg.Done()
-			posts, err := r.fetchSubreddit(ctx, subreddit)
-			results[idx] = subredditResult{subreddit: subreddit, posts: posts, err: err}
+			posts, err := r.fetchSubreddit(ctx, spec)
+			results[idx] = subredditResult{subreddit: spec.Name, posts: posts, err: err}
 		}(i, sub)
 	}
 
@@ -68,12 +298,13 @@ func (r *Reddit) Fetch(ctx context.Context) (any, error) {
 	var firstErr error
 	for _, res := range results {
 		if res.err != nil {
+			slog.WarnContext(ctx, "Failed to fetch subreddit", "subreddit", res.subreddit, "error", res.err)
 			if firstErr == nil {
 				firstErr = res.err
 			}
 			continue
 		}
-		bySubreddit[res.subreddit] = res.posts
+		bySubreddit[res.subreddit] = screen(ctx, res.posts)
 	}
 
 	if len(bySubreddit) == 0 {
@@ -83,73 +314,253 @@ func (r *Reddit) Fetch(ctx context.Context) (any, error) {
 		return []RedditPost{}, nil
 	}
 
-	return mergePosts(bySubreddit, r.subreddits), nil
+	return mergePosts(bySubreddit, r.query.Subreddits, r.query.Count), nil
 }
 
-func (r *Reddit) fetchSubreddit(ctx context.Context, subreddit string) ([]RedditPost, error) {
-	url := fmt.Sprintf("%s/r/%s/top/.json?t=day&limit=5", r.baseURL, subreddit)
+// listingURL builds the listing URL for a subreddit. The time window only
+// applies to the top listing. More posts than needed are requested so that
+// min_score and the screens leave enough to choose from.
+func (r *Reddit) listingURL(spec SubredditSpec) string {
+	limit := min(100, max(10, 2*r.query.Count))
+	u := fmt.Sprintf("%s/r/%s/%s/.json?limit=%d", r.baseURL, spec.Name, r.query.Listing, limit)
+	if r.query.Listing == "top" {
+		u += "&t=" + spec.Time
+	}
+	return u
+}
 
-	cmd := exec.CommandContext(ctx, "curl", "-s",
-		"--user-agent", "burrow/1.0 (by /u/kaktus_jack; info@burrow.janiskrasemann.com)",
-		url,
-	)
+func (r *Reddit) fetchSubreddit(ctx context.Context, spec SubredditSpec) ([]RedditPost, error) {
+	subreddit := spec.Name
+	if err := r.waitForRateLimit(ctx); err != nil {
+		return nil, fmt.Errorf("fetching r/%s: %w", subreddit, err)
+	}
 
-	out, err := cmd.Output()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.listingURL(spec), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", r.userAgent)
+
+	if r.creds != nil {
+		token, err := r.accessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "bearer "+token)
+	}
+
+	resp, err := r.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetching reddit posts for r/%s: %w", subreddit, err)
 	}
+	defer resp.Body.Close()
+
+	r.updateRateLimit(resp.Header)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		err := statusError(resp, "reddit rate limited r/%s (HTTP 429)%s", subreddit, r.resetHint())
+		if err.RetryAfter == 0 {
+			err.RetryAfter = r.untilReset()
+		}
+		return nil, err
+	case resp.StatusCode == http.StatusUnauthorized && r.creds != nil:
+		r.dropToken()
+		return nil, fmt.Errorf("reddit rejected the access token for r/%s (HTTP 401)", subreddit)
+	case resp.StatusCode != http.StatusOK:
+		return nil, statusError(resp, "reddit returned HTTP %d for r/%s", resp.StatusCode, subreddit)
+	}
 
 	var result redditResponse
-	if err := json.Unmarshal(out, &result); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("decoding Reddit response for r/%s: %w", subreddit, err)
 	}
 
 	posts := make([]RedditPost, 0, len(result.Data.Children))
 	for _, child := range result.Data.Children {
 		p := child.Data
+		if p.Score < r.query.MinScore {
+			continue
+		}
 		if p.Subreddit == "" {
 			p.Subreddit = subreddit
 		}
+		p.Ranking = rank(ctx, p, p.Score)
 		posts = append(posts, p)
 	}
 
 	return posts, nil
 }
 
-// mergePosts combines posts from multiple subreddits, guaranteeing at least one
-// post per subreddit. Remaining slots are filled with the highest-scored posts.
-func mergePosts(bySubreddit map[string][]RedditPost, subredditOrder []string) []RedditPost {
-	total := len(bySubreddit)
-	if total < 5 {
-		total = 5
+// accessToken returns a cached OAuth token, requesting a new one shortly
+// before the old one expires.
+func (r *Reddit) accessToken(ctx context.Context) (string, error) {
+	r.tokenMu.Lock()
+	defer r.tokenMu.Unlock()
+
+	if r.token != "" && time.Now().Before(r.tokenExpiry) {
+		return r.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if r.creds.Username != "" {
+		form = url.Values{
+			"grant_type": {"password"},
+			"username":   {r.creds.Username},
+			"password":   {r.creds.Password},
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+	req.SetBasicAuth(r.creds.ClientID, r.creds.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", r.userAgent)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting reddit access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp, "reddit token endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("decoding reddit token response: %w", err)
+	}
+	if tok.Error != "" {
+		return "", fmt.Errorf("reddit token request failed: %s", tok.Error)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("reddit token response has no access token")
+	}
+
+	r.token = tok.AccessToken
+	r.tokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return r.token, nil
+}
+
+func (r *Reddit) dropToken() {
+	r.tokenMu.Lock()
+	defer r.tokenMu.Unlock()
+	r.token = ""
+}
+
+// updateRateLimit records the quota Reddit reports in X-Ratelimit-Remaining
+// (requests left, sent as a float) and X-Ratelimit-Reset (seconds until the
+// window resets).
+func (r *Reddit) updateRateLimit(h http.Header) {
+	remaining, err := strconv.ParseFloat(h.Get("X-Ratelimit-Remaining"), 64)
+	if err != nil {
+		return
+	}
+	reset, err := strconv.Atoi(h.Get("X-Ratelimit-Reset"))
+	if err != nil {
+		return
+	}
+
+	r.limitMu.Lock()
+	defer r.limitMu.Unlock()
+	r.remaining = remaining
+	r.resetAt = time.Now().Add(time.Duration(reset) * time.Second)
+}
+
+// waitForRateLimit blocks until the rate limit window resets if the quota is
+// used up. It fails instead if that would take longer than
+// maxRedditRateLimitWait or outlast the context.
+func (r *Reddit) waitForRateLimit(ctx context.Context) error {
+	r.limitMu.Lock()
+	exhausted := r.remaining >= 0 && r.remaining < 1
+	wait := time.Until(r.resetAt)
+	r.limitMu.Unlock()
+
+	if !exhausted || wait <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); wait > maxRedditRateLimitWait || (ok && time.Now().Add(wait).After(deadline)) {
+		return fmt.Errorf("reddit rate limit exhausted, resets in %s", wait.Round(time.Second))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Reddit) resetHint() string {
+	if wait := r.untilReset(); wait > 0 {
+		return fmt.Sprintf(", resets in %s", wait.Round(time.Second))
+	}
+	return ""
+}
+
+// untilReset returns how long until the rate limit window resets, or zero if
+// Reddit has not reported one.
+func (r *Reddit) untilReset() time.Duration {
+	r.limitMu.Lock()
+	defer r.limitMu.Unlock()
+	return max(0, time.Until(r.resetAt))
+}
+
+// mergePosts combines posts from multiple subreddits. Each subreddit first
+// gets its guaranteed number of top posts; the slots left up to total go to
+// the remaining posts with the highest weighted score, where the score is the
+// ranking score if there is one and the upvotes otherwise. The result is
+// sorted by weighted score.
+func mergePosts(bySubreddit map[string][]RedditPost, subs []SubredditSpec, total int) []RedditPost {
+	// Keyed by permalink, since the subreddit name Reddit returns may differ
+	// in case from the configured one.
+	weight := make(map[string]float64)
+	for _, s := range subs {
+		for _, p := range bySubreddit[s.Name] {
+			weight[p.Permalink] = s.weight()
+		}
+	}
+	weighted := func(p RedditPost) float64 {
+		return p.score() * weight[p.Permalink]
 	}
 
 	var guaranteed []RedditPost
 	used := make(map[string]bool)
 
-	// Take the top post from each subreddit (guarantee)
-	for _, sub := range subredditOrder {
-		posts := bySubreddit[sub]
-		if len(posts) > 0 {
-			guaranteed = append(guaranteed, posts[0])
-			used[posts[0].Permalink] = true
+	// Take the top posts from each subreddit (guarantee)
+	for _, s := range subs {
+		posts := slices.Clone(bySubreddit[s.Name])
+		sort.SliceStable(posts, func(i, j int) bool {
+			return posts[i].score() > posts[j].score()
+		})
+		for _, p := range posts[:min(max(s.guaranteed(), 0), len(posts))] {
+			guaranteed = append(guaranteed, p)
+			used[p.Permalink] = true
 		}
 	}
 
 	// Collect remaining posts from all subreddits
 	var remaining []RedditPost
-	for _, sub := range subredditOrder {
-		posts := bySubreddit[sub]
-		for _, p := range posts {
+	for _, s := range subs {
+		for _, p := range bySubreddit[s.Name] {
 			if !used[p.Permalink] {
 				remaining = append(remaining, p)
 			}
 		}
 	}
 
-	// Sort remaining by score descending
-	sort.Slice(remaining, func(i, j int) bool {
-		return remaining[i].Score > remaining[j].Score
+	// Sort remaining by weighted score descending
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return weighted(remaining[i]) > weighted(remaining[j])
 	})
 
 	// Fill up to total
@@ -159,9 +570,9 @@ func mergePosts(bySubreddit map[string][]RedditPost, subredditOrder []string) []
 		result = append(result, remaining[i])
 	}
 
-	// Sort final result by score descending
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Score > result[j].Score
+	// Sort final result by weighted score descending
+	sort.SliceStable(result, func(i, j int) bool {
+		return weighted(result[i]) > weighted(result[j])
 	})
 
 	return result
@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -24,7 +26,7 @@ func TestRedditFetch(t *testing.T) {
 	}))
 	defer server.Close()
 
-	reddit := NewReddit([]string{"de"})
+	reddit := NewReddit(http.DefaultClient, RedditQuery{Subreddits: subreddits("de")}, "", nil)
 	reddit.baseURL = server.URL
 
 	result, err := reddit.Fetch(context.Background())
@@ -85,7 +87,7 @@ func TestRedditMultiSubreddit(t *testing.T) {
 	}))
 	defer server.Close()
 
-	reddit := NewReddit([]string{"golang", "rust", "python"})
+	reddit := NewReddit(http.DefaultClient, RedditQuery{Subreddits: subreddits("golang", "rust", "python")}, "", nil)
 	reddit.baseURL = server.URL
 
 	result, err := reddit.Fetch(context.Background())
@@ -154,7 +156,7 @@ func TestRedditGuaranteeLowScoreSubreddit(t *testing.T) {
 	}))
 	defer server.Close()
 
-	reddit := NewReddit([]string{"popular", "niche"})
+	reddit := NewReddit(http.DefaultClient, RedditQuery{Subreddits: subreddits("popular", "niche")}, "", nil)
 	reddit.baseURL = server.URL
 
 	result, err := reddit.Fetch(context.Background())
@@ -201,7 +203,7 @@ func TestMergePosts(t *testing.T) {
 	}
 	order := []string{"a", "b", "c"}
 
-	posts := mergePosts(bySubreddit, order)
+	posts := mergePosts(bySubreddit, subreddits(order...), 5)
 
 	// max(5, 3) = 5 posts
 	if len(posts) != 5 {
@@ -226,3 +228,216 @@ func TestMergePosts(t *testing.T) {
 		}
 	}
 }
+
+func TestRedditHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Remaining", "0.0")
+		w.Header().Set("X-Ratelimit-Reset", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("<html>Too Many Requests</html>"))
+	}))
+	defer server.Close()
+
+	reddit := NewReddit(http.DefaultClient, RedditQuery{Subreddits: subreddits("de")}, "", nil)
+	reddit.baseURL = server.URL
+
+	_, err := reddit.Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "HTTP 429") {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+
+	// The quota is used up for another two minutes, so the next fetch gives
+	// up without a request.
+	_, err = reddit.Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rate limit exhausted") {
+		t.Fatalf("expected exhausted quota error, got %v", err)
+	}
+}
+
+func TestRedditOAuth(t *testing.T) {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "test-agent" {
+			t.Errorf("unexpected User-Agent: %q", r.Header.Get("User-Agent"))
+		}
+		if r.URL.Path == "/api/v1/access_token" {
+			tokenRequests++
+			id, secret, _ := r.BasicAuth()
+			if id != "app-id" || secret != "app-secret" {
+				t.Errorf("unexpected client credentials %q/%q", id, secret)
+			}
+			r.ParseForm()
+			if r.Form.Get("grant_type") != "password" || r.Form.Get("username") != "burrow" {
+				t.Errorf("unexpected token form %v", r.Form)
+			}
+			w.Write([]byte(`{"access_token": "tok", "token_type": "bearer", "expires_in": 86400}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data": {"children": [{"data": {"title": "Post", "score": 1, "permalink": "/r/de/1/"}}]}}`))
+	}))
+	defer server.Close()
+
+	reddit := NewReddit(http.DefaultClient, RedditQuery{Subreddits: subreddits("de", "golang")}, "test-agent", &RedditCredentials{
+		ClientID: "app-id", ClientSecret: "app-secret", Username: "burrow", Password: "hunter2",
+	})
+	reddit.baseURL = server.URL
+	reddit.tokenURL = server.URL + "/api/v1/access_token"
+
+	result, err := reddit.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if posts := result.([]RedditPost); len(posts) != 2 {
+		t.Errorf("expected 2 posts, got %d", len(posts))
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected the token to be requested once, got %d", tokenRequests)
+	}
+}
+
+// subreddits returns specs with the default weight and guarantee.
+func subreddits(names ...string) []SubredditSpec {
+	specs := make([]SubredditSpec, len(names))
+	for i, n := range names {
+		specs[i] = SubredditSpec{Name: n}
+	}
+	return specs
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestRedditListingOptions(t *testing.T) {
+	queries := make(map[string]string)
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries[r.URL.Path] = r.URL.RawQuery
+		mu.Unlock()
+		w.Write([]byte(`{"data": {"children": [
+			{"data": {"title": "Big", "score": 120, "permalink": "` + r.URL.Path + `big/"}},
+			{"data": {"title": "Small", "score": 3, "permalink": "` + r.URL.Path + `small/"}}
+		]}}`))
+	}))
+	defer server.Close()
+
+	reddit := NewReddit(http.DefaultClient, RedditQuery{
+		Subreddits: []SubredditSpec{{Name: "de"}, {Name: "DnD", Time: "week"}},
+		MinScore:   10,
+		Count:      8,
+	}, "", nil)
+	reddit.baseURL = server.URL
+
+	result, err := reddit.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range result.([]RedditPost) {
+		if p.Score < 10 {
+			t.Errorf("expected posts below min_score to be dropped, got %q", p.Title)
+		}
+	}
+
+	if q := queries["/r/de/top/.json"]; q != "limit=16&t=day" {
+		t.Errorf("unexpected query for r/de: %q", q)
+	}
+	if q := queries["/r/DnD/top/.json"]; q != "limit=16&t=week" {
+		t.Errorf("unexpected query for r/DnD: %q", q)
+	}
+
+	hot := NewReddit(http.DefaultClient, RedditQuery{Subreddits: subreddits("de"), Listing: "hot"}, "", nil)
+	if got := hot.listingURL(hot.query.Subreddits[0]); !strings.HasSuffix(got, "/r/de/hot/.json?limit=10") {
+		t.Errorf("expected no time window for hot listing, got %q", got)
+	}
+}
+
+func TestMergePostsWeightsAndGuarantees(t *testing.T) {
+	bySubreddit := map[string][]RedditPost{
+		"big": {
+			{Title: "B1", Score: 1000, Permalink: "/big/1", Subreddit: "big"},
+			{Title: "B2", Score: 900, Permalink: "/big/2", Subreddit: "big"},
+			{Title: "B3", Score: 800, Permalink: "/big/3", Subreddit: "big"},
+		},
+		"slow": {
+			{Title: "S1", Score: 50, Permalink: "/slow/1", Subreddit: "slow"},
+			{Title: "S2", Score: 40, Permalink: "/slow/2", Subreddit: "slow"},
+			{Title: "S3", Score: 30, Permalink: "/slow/3", Subreddit: "slow"},
+		},
+	}
+	subs := []SubredditSpec{
+		{Name: "big"},
+		{Name: "slow", Guaranteed: ptr(2)},
+	}
+
+	posts := mergePosts(bySubreddit, subs, 4)
+	got := titles(posts)
+	if got != "B1 B2 S1 S2" {
+		t.Errorf("unexpected posts with guarantees: %s", got)
+	}
+
+	subs[1] = SubredditSpec{Name: "slow", Weight: ptr(25.0)}
+	posts = mergePosts(bySubreddit, subs, 4)
+	if got := titles(posts); got != "S1 B1 S2 B2" {
+		t.Errorf("unexpected posts with weights: %s", got)
+	}
+
+	subs[1] = SubredditSpec{Name: "slow", Weight: ptr(0.0), Guaranteed: ptr(2)}
+	posts = mergePosts(bySubreddit, subs, 4)
+	if got := titles(posts); got != "B1 B2 S1 S2" {
+		t.Errorf("expected weight 0 to leave only the guaranteed posts, got %s", got)
+	}
+
+	subs[1] = SubredditSpec{Name: "slow", Weight: ptr(25.0), Guaranteed: ptr(0)}
+	posts = mergePosts(bySubreddit, subs, 2)
+	if got := titles(posts); got != "S1 B1" {
+		t.Errorf("expected guaranteed 0 to let the subreddit compete for every slot, got %s", got)
+	}
+}
+
+func TestRedditQueryValidate(t *testing.T) {
+	for _, q := range []RedditQuery{
+		{Subreddits: subreddits("de"), Listing: "best"},
+		{Subreddits: []SubredditSpec{{Name: "de", Time: "fortnight"}}},
+		{Subreddits: []SubredditSpec{{Name: "de", Weight: ptr(-1.0)}}},
+		{Subreddits: []SubredditSpec{{Name: "de", Guaranteed: ptr(3)}, {Name: "DnD", Guaranteed: ptr(3)}}},
+		{Subreddits: []SubredditSpec{{Name: "de", Guaranteed: ptr(3)}}, Count: 2},
+	} {
+		if err := q.validate(); err == nil {
+			t.Errorf("expected error for %+v", q)
+		}
+	}
+}
+
+func TestRedditQueryValidateZeros(t *testing.T) {
+	q := RedditQuery{Subreddits: []SubredditSpec{
+		{Name: "de", Weight: ptr(0.0), Guaranteed: ptr(4)},
+		{Name: "DnD", Guaranteed: ptr(0)},
+	}, Count: 4}
+	if err := q.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func titles(posts []RedditPost) string {
+	var out []string
+	for _, p := range posts {
+		out = append(out, p.Title)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestMergePostsByRanking(t *testing.T) {
+	bySubreddit := map[string][]RedditPost{
+		"a": {
+			{Title: "A1", Score: 100, Permalink: "/a/1", Ranking: &Ranking{Score: 1}},
+			{Title: "A2", Score: 10, Permalink: "/a/2", Ranking: &Ranking{Score: 50}},
+		},
+	}
+	posts := mergePosts(bySubreddit, subreddits("a"), 1)
+	if got := titles(posts); got != "A2" {
+		t.Errorf("expected the best ranked post to be guaranteed, got %s", got)
+	}
+}
@@ -0,0 +1,158 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Env carries the shared dependencies handed to every source factory.
+type Env struct {
+	Client *http.Client
+	// Geocoder resolves location names; nil if there is none.
+	Geocoder *Geocoder
+}
+
+// Options is the raw options block of a source entry. Factories never see it
+// directly; Register decodes it into the factory's own typed options struct.
+type Options interface {
+	Decode(v any) error
+}
+
+type factory func(env Env, opts Options) (Fetcher, error)
+
+type registration struct {
+	build factory
+	// check decodes, validates and resolves an options block without
+	// building.
+	check func(env Env, opts Options) error
+	// options is the type the options block decodes into.
+	options reflect.Type
+}
+
+var registry = map[string]registration{}
+
+// validator is implemented by options structs that check their values. It
+// reports every problem it finds, joined into one error, ideally as
+// *OptionError so each can be traced to its line in the config file.
+type validator interface {
+	validate() error
+}
+
+// resolver is implemented by options structs that look up some of their
+// values before use, like the coordinates of a location name. It runs after
+// validate succeeds.
+type resolver interface {
+	resolve(ctx context.Context, env Env) error
+}
+
+// OptionError is an invalid value of a source option.
+type OptionError struct {
+	Key string
+	Msg string
+}
+
+func (e *OptionError) Error() string {
+	return e.Key + ": " + e.Msg
+}
+
+func optionErrorf(key, format string, args ...any) error {
+	return &OptionError{Key: key, Msg: fmt.Sprintf(format, args...)}
+}
+
+// checkURL reports whether raw is an absolute http or https URL.
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", raw)
+	}
+	return nil
+}
+
+// Register makes a source type available to Build. The options block of every
+// source entry with that type is decoded into a fresh O, validated if O has a
+// validate method and resolved if *O has a resolve method, before build is
+// called. It is meant to be called from init and panics on duplicate types.
+func Register[O any](typ string, build func(env Env, opts O) (Fetcher, error)) {
+	if _, dup := registry[typ]; dup {
+		panic(fmt.Sprintf("fetcher: source type %q registered twice", typ))
+	}
+	decode := func(env Env, raw Options) (O, error) {
+		var opts O
+		if raw != nil {
+			if err := raw.Decode(&opts); err != nil {
+				return opts, fmt.Errorf("decoding %s options: %w", typ, err)
+			}
+		}
+		if v, ok := any(opts).(validator); ok {
+			if err := v.validate(); err != nil {
+				return opts, err
+			}
+		}
+		if r, ok := any(&opts).(resolver); ok {
+			return opts, r.resolve(context.Background(), env)
+		}
+		return opts, nil
+	}
+	registry[typ] = registration{
+		build: func(env Env, raw Options) (Fetcher, error) {
+			opts, err := decode(env, raw)
+			if err != nil {
+				return nil, err
+			}
+			return build(env, opts)
+		},
+		check: func(env Env, raw Options) error {
+			_, err := decode(env, raw)
+			return err
+		},
+		options: reflect.TypeFor[O](),
+	}
+}
+
+// Build creates the fetcher for a source entry of the given type.
+func Build(typ string, env Env, opts Options) (Fetcher, error) {
+	r, ok := registry[typ]
+	if !ok {
+		return nil, unknownType(typ)
+	}
+	return r.build(env, opts)
+}
+
+// Validate checks a source entry's options without building its fetcher.
+// Values that need looking up, like location names, are resolved through env.
+func Validate(typ string, env Env, opts Options) error {
+	r, ok := registry[typ]
+	if !ok {
+		return unknownType(typ)
+	}
+	return r.check(env, opts)
+}
+
+func unknownType(typ string) error {
+	return fmt.Errorf("unknown source type %q (known types: %s)", typ, strings.Join(Types(), ", "))
+}
+
+// OptionTypes maps every registered source type to the struct its options
+// block decodes into.
+func OptionTypes() map[string]reflect.Type {
+	types := make(map[string]reflect.Type, len(registry))
+	for typ, r := range registry {
+		types[typ] = r.options
+	}
+	return types
+}
+
+// Types returns all registered source types in alphabetical order.
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type yamlOptions string
+
+func (o yamlOptions) Decode(v any) error { return yaml.Unmarshal([]byte(o), v) }
+
+func TestBuildRegisteredType(t *testing.T) {
+	f, err := Build("reddit", Env{Client: http.DefaultClient}, yamlOptions("subreddits: [de, {name: golang, time: week}]"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reddit, ok := f.(*Reddit)
+	if !ok {
+		t.Fatalf("expected *Reddit, got %T", f)
+	}
+	subs := reddit.query.Subreddits
+	if len(subs) != 2 || subs[1].Name != "golang" || subs[1].Time != "week" || subs[0].Time != "day" {
+		t.Errorf("unexpected subreddits: %+v", subs)
+	}
+}
+
+func TestBuildUnknownType(t *testing.T) {
+	_, err := Build("gopher", Env{}, nil)
+	if err == nil {
+		t.Fatal("expected error for unknown source type")
+	}
+	if !strings.Contains(err.Error(), `"gopher"`) {
+		t.Errorf("expected error to name the type, got %v", err)
+	}
+}
+
+func TestBuildBadOptions(t *testing.T) {
+	_, err := Build("weather", Env{}, yamlOptions("latitude: north"))
+	if err == nil {
+		t.Fatal("expected error for malformed options block")
+	}
+	if !strings.Contains(err.Error(), "weather options") {
+		t.Errorf("expected error to mention weather options, got %v", err)
+	}
+}
+
+func TestBuildFactoryValidation(t *testing.T) {
+	if _, err := Build("reddit", Env{}, yamlOptions("{}")); err == nil {
+		t.Error("expected error for reddit source without subreddits")
+	}
+}
+
+func TestDecodeDataKnowsEveryType(t *testing.T) {
+	for _, typ := range Types() {
+		if _, err := DecodeData(typ, []byte("null")); err != nil {
+			t.Errorf("%s: %v", typ, err)
+		}
+	}
+}
+
+func TestValidateReportsEveryOption(t *testing.T) {
+	err := Validate("weather", Env{}, yamlOptions("latitude: 95\nlongitude: -200"))
+	if err == nil {
+		t.Fatal("expected errors for out-of-range coordinates")
+	}
+	var keys []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var opt *OptionError
+		if !errors.As(e, &opt) {
+			t.Fatalf("expected an *OptionError, got %T", e)
+		}
+		keys = append(keys, opt.Key)
+	}
+	if strings.Join(keys, ",") != "latitude,longitude" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if err := Validate("weather", Env{}, yamlOptions("{}")); err == nil {
+		t.Error("expected error for weather source without coordinates")
+	}
+	if err := Validate("feed", Env{}, yamlOptions("feeds: [example.com/rss]")); err == nil {
+		t.Error("expected error for a feed without scheme")
+	}
+	if err := Validate("hackernews", Env{}, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOptionTypesCoverEveryType(t *testing.T) {
+	types := OptionTypes()
+	for _, typ := range Types() {
+		if types[typ] == nil || types[typ].Kind() != reflect.Struct {
+			t.Errorf("%s: unexpected options type %v", typ, types[typ])
+		}
+	}
+}
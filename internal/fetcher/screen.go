@@ -0,0 +1,48 @@
+package fetcher
+
+import "context"
+
+// A Screen vets candidate items before a fetcher makes its selection. Items it
+// rejects are never selected, so the next-best candidates take their place.
+type Screen func(item Item) bool
+
+type screenKey struct{}
+
+// WithScreen returns a context under which fetchers skip the items s rejects,
+// in addition to those rejected by screens already on ctx.
+func WithScreen(ctx context.Context, s Screen) context.Context {
+	prev := screens(ctx)
+	all := make([]Screen, 0, len(prev)+1)
+	all = append(all, prev...)
+	all = append(all, s)
+	return context.WithValue(ctx, screenKey{}, all)
+}
+
+func screens(ctx context.Context) []Screen {
+	s, _ := ctx.Value(screenKey{}).([]Screen)
+	return s
+}
+
+// Admit reports whether item passes every screen on ctx.
+func Admit(ctx context.Context, item Item) bool {
+	for _, s := range screens(ctx) {
+		if !s(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// screen returns the candidates that pass every screen on ctx.
+func screen[T Item](ctx context.Context, candidates []T) []T {
+	if len(screens(ctx)) == 0 {
+		return candidates
+	}
+	kept := make([]T, 0, len(candidates))
+	for _, c := range candidates {
+		if Admit(ctx, c) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
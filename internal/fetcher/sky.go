@@ -0,0 +1,90 @@
+package fetcher
+
+import (
+	"math"
+	"time"
+)
+
+// Sky is the sun and moon at a location today.
+type Sky struct {
+	Sunrise  time.Time
+	Sunset   time.Time
+	Daylight time.Duration
+	// YesterdayDaylight is zero if the forecast did not include yesterday.
+	YesterdayDaylight time.Duration
+	Moon              MoonPhase
+}
+
+// MoonPhase is the phase of the moon at a point in time.
+type MoonPhase struct {
+	// Phase numbers the eight phases from 0, the new moon, through 4, the
+	// full moon, to 7, the waning crescent.
+	Phase int
+	// Name is the phase's name, like "Waxing gibbous".
+	Name string
+	// Age is the fraction of the lunar cycle since the last new moon, from 0
+	// to 1.
+	Age float64
+	// Illumination is the percentage of the moon's disc that is lit.
+	Illumination float64
+}
+
+const synodicMonth = 29.530588853 * 24 * float64(time.Hour)
+
+// newMoon is a known new moon that phases are counted from.
+var newMoon = time.Date(2000, 1, 6, 18, 14, 0, 0, time.UTC)
+
+var moonPhaseNames = []string{
+	"New moon",
+	"Waxing crescent",
+	"First quarter",
+	"Waxing gibbous",
+	"Full moon",
+	"Waning gibbous",
+	"Last quarter",
+	"Waning crescent",
+}
+
+// moonPhase computes the phase of the moon at t from the mean length of the
+// lunar cycle, which is accurate to within a day or so.
+func moonPhase(t time.Time) MoonPhase {
+	age := math.Mod(float64(t.Sub(newMoon)), synodicMonth) / synodicMonth
+	if age < 0 {
+		age++
+	}
+	phase := int(age*8+0.5) % 8
+	return MoonPhase{
+		Phase:        phase,
+		Name:         moonPhaseNames[phase],
+		Age:          age,
+		Illumination: 50 * (1 - math.Cos(2*math.Pi*age)),
+	}
+}
+
+// sky returns today's sun and moon from the daily forecast, or nil if the
+// forecast has no sunrise or sunset for today. now is when the moon phase is
+// computed for.
+func (r *openMeteoResponse) sky(today int, now time.Time) *Sky {
+	d := r.Daily
+	if today >= len(d.Sunrise) || today >= len(d.Sunset) {
+		return nil
+	}
+	sunrise, err1 := time.ParseInLocation("2006-01-02T15:04", d.Sunrise[today], r.location())
+	sunset, err2 := time.ParseInLocation("2006-01-02T15:04", d.Sunset[today], r.location())
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	s := &Sky{Sunrise: sunrise, Sunset: sunset, Moon: moonPhase(now)}
+	if today < len(d.Daylight) {
+		s.Daylight = seconds(d.Daylight[today])
+	}
+	if today > 0 && today-1 < len(d.Daylight) {
+		s.YesterdayDaylight = seconds(d.Daylight[today-1])
+	}
+	return s
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s)) * time.Second
+}
@@ -0,0 +1,51 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusError is returned when an API answers with a status other than the one
+// expected, so callers can tell server errors and rate limits apart from
+// other failures.
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the delay the server asked for in a Retry-After header, or
+	// zero if it sent none.
+	RetryAfter time.Duration
+
+	msg string
+}
+
+func (e *StatusError) Error() string { return e.msg }
+
+// statusError builds a StatusError for resp with the formatted message.
+func statusError(resp *http.Response, format string, args ...any) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		msg:        fmt.Sprintf(format, args...),
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package fetcher
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-5":                            0,
+		"Sun, 01 Mar 2026 07:00:30 GMT": 30 * time.Second,
+		"Sun, 01 Mar 2026 06:59:00 GMT": 0,
+		"soon":                          0,
+	}
+	for in, want := range cases {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"3"}}}
+
+	err := statusError(resp, "API returned status %d", resp.StatusCode)
+
+	if err.Error() != "API returned status 503" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if err.StatusCode != 503 || err.RetryAfter != 3*time.Second {
+		t.Errorf("unexpected error %+v", err)
+	}
+}
@@ -29,6 +29,24 @@ type unsplashResponse struct {
 	} `json:"user"`
 }
 
+type unsplashOptions struct {
+	APIToken string `yaml:"api_token"`
+	Query    string `yaml:"query"`
+}
+
+func (o unsplashOptions) validate() error {
+	if o.APIToken == "" {
+		return optionErrorf("api_token", "unsplash source needs an api_token")
+	}
+	return nil
+}
+
+func init() {
+	Register(TypeUnsplash, func(env Env, o unsplashOptions) (Fetcher, error) {
+		return NewUnsplash(env.Client, o.APIToken, o.Query), nil
+	})
+}
+
 type Unsplash struct {
 	client        *http.Client
 	accessKey     string
@@ -50,6 +68,20 @@ func (u *Unsplash) SetTopicQuery(topic string) {
 
 func (u *Unsplash) Name() string { return "Unsplash" }
 
+// Prepare uses the Readwise highlight's BookTitle as the topic query for
+// contextual imagery.
+func (u *Unsplash) Prepare(results []Result) {
+	for _, r := range results {
+		if r.Type == TypeReadwise && r.Error == nil {
+			if highlights, ok := r.Data.([]Highlight); ok && len(highlights) > 0 {
+				if highlights[0].BookTitle != "" {
+					u.SetTopicQuery(highlights[0].BookTitle)
+				}
+			}
+		}
+	}
+}
+
 func (u *Unsplash) Fetch(ctx context.Context) (any, error) {
 	if u.accessKey == "" {
 		return nil, fmt.Errorf("Unsplash access key not configured")
@@ -83,7 +115,7 @@ func (u *Unsplash) fetchRandom(ctx context.Context, query string) (*UnsplashImag
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Unsplash API returned status %d", resp.StatusCode)
+		return nil, statusError(resp, "Unsplash API returned status %d", resp.StatusCode)
 	}
 
 	var result unsplashResponse
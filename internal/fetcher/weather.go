@@ -1,114 +1,699 @@
 package fetcher
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
+// WeatherData is the forecast for one location.
 type WeatherData struct {
-	Temperature   float64
-	HighTemp      float64
-	LowTemp       float64
+	// Error tells why the location's forecast could not be fetched; only
+	// Location is set along with it.
+	Error       string
+	Temperature float64
+	HighTemp    float64
+	LowTemp     float64
+	// Precipitation is today's highest probability of precipitation in
+	// percent, and PrecipitationSum the expected amount.
+	Precipitation    float64
+	PrecipitationSum float64
+	WindSpeed        float64
+	WeatherCode      int
+	Description      string
+	Location         string
+	Units            WeatherUnits
+	// Hourly is today's forecast for the hours of the hourly strip.
+	Hourly []HourlyWeather
+	// Outlook is the forecast for the days after today, if configured.
+	Outlook []DailyWeather
+	// Umbrella is the rain outlook for the commute, or nil if the forecast
+	// covers none of the commute hours.
+	Umbrella *Umbrella
+	// AirQuality is nil unless the source reports it, or if it could not be
+	// fetched.
+	AirQuality *AirQuality
+	// Sky is nil if the forecast has no sunrise and sunset.
+	Sky *Sky
+}
+
+// Unit systems of the units option.
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// WeatherUnits names the units of a forecast's values.
+type WeatherUnits struct {
+	// Temperature is "°C" or "°F".
+	Temperature string
+	// WindSpeed is "km/h" or "mph".
+	WindSpeed string
+	// Precipitation is "mm" or "in".
+	Precipitation string
+}
+
+var weatherUnits = map[string]WeatherUnits{
+	UnitsMetric:   {Temperature: "°C", WindSpeed: "km/h", Precipitation: "mm"},
+	UnitsImperial: {Temperature: "°F", WindSpeed: "mph", Precipitation: "in"},
+}
+
+// unitParams are the Open-Meteo query parameters of each unit system; metric
+// is the API's default.
+var unitParams = map[string]string{
+	UnitsMetric:   "",
+	UnitsImperial: "&temperature_unit=fahrenheit&wind_speed_unit=mph&precipitation_unit=inch",
+}
+
+// HourlyWeather is the forecast for one hour.
+type HourlyWeather struct {
+	Time        time.Time
+	Temperature float64
+	WeatherCode int
+	Description string
+	// Precipitation is the probability of precipitation in percent.
 	Precipitation float64
-	WeatherCode   int
-	Description   string
-	Location      string
+	// Likely is set for the hours of the hourly strip whose probability of
+	// precipitation reaches the umbrella threshold.
+	Likely bool
+}
+
+// DailyWeather is the forecast for one day.
+type DailyWeather struct {
+	Date        time.Time
+	HighTemp    float64
+	LowTemp     float64
+	WeatherCode int
+	Description string
+	// Precipitation is the day's highest probability of precipitation in
+	// percent, and PrecipitationSum the expected amount.
+	Precipitation    float64
+	PrecipitationSum float64
+}
+
+// Umbrella tells whether rain is likely during the commute.
+type Umbrella struct {
+	// Needed is set if the probability of precipitation reaches the
+	// threshold in any commute hour.
+	Needed bool
+	// Chance is the highest probability of precipitation during the commute
+	// in percent, and At the hour it occurs.
+	Chance float64
+	At     time.Time
 }
 
 type openMeteoResponse struct {
+	Timezone  string `json:"timezone"`
+	UTCOffset int    `json:"utc_offset_seconds"`
+	Current   struct {
+		Time        string  `json:"time"`
+		Temperature float64 `json:"temperature_2m"`
+		WeatherCode int     `json:"weather_code"`
+		WindSpeed   float64 `json:"wind_speed_10m"`
+	} `json:"current"`
 	Hourly struct {
-		Time        []string  `json:"time"`
-		Temperature []float64 `json:"temperature_2m"`
-		WeatherCode []int     `json:"weather_code"`
+		Time          []string  `json:"time"`
+		Temperature   []float64 `json:"temperature_2m"`
+		WeatherCode   []int     `json:"weather_code"`
+		Precipitation []float64 `json:"precipitation_probability"`
 	} `json:"hourly"`
 	Daily struct {
+		Time           []string  `json:"time"`
+		WeatherCode    []int     `json:"weather_code"`
 		TemperatureMax []float64 `json:"temperature_2m_max"`
 		TemperatureMin []float64 `json:"temperature_2m_min"`
 		Precipitation  []float64 `json:"precipitation_probability_max"`
+		PrecipSum      []float64 `json:"precipitation_sum"`
+		Sunrise        []string  `json:"sunrise"`
+		Sunset         []string  `json:"sunset"`
+		// Daylight is in seconds.
+		Daylight []float64 `json:"daylight_duration"`
 	} `json:"daily"`
 }
 
+// HourlySpec selects the hours of the hourly strip: every Every hours from
+// From to To, both inclusive.
+type HourlySpec struct {
+	From  int `yaml:"from"`
+	To    int `yaml:"to"`
+	Every int `yaml:"every"`
+}
+
+// commuteWindow is a time of day span, as offsets from midnight.
+type commuteWindow struct {
+	start, end time.Duration
+}
+
+// parseCommuteWindow parses a span like "07:00-09:00".
+func parseCommuteWindow(s string) (commuteWindow, error) {
+	from, to, ok := strings.Cut(s, "-")
+	start, err1 := time.Parse("15:04", strings.TrimSpace(from))
+	end, err2 := time.Parse("15:04", strings.TrimSpace(to))
+	if !ok || err1 != nil || err2 != nil || !end.After(start) {
+		return commuteWindow{}, fmt.Errorf("invalid commute window %q (want e.g. 07:00-09:00)", s)
+	}
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return commuteWindow{start: start.Sub(midnight), end: end.Sub(midnight)}, nil
+}
+
+func (c commuteWindow) contains(t time.Time) bool {
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	return d >= c.start && d <= c.end
+}
+
+// weatherForecast selects what a weather source reports beyond the current
+// conditions.
+type weatherForecast struct {
+	hourly      HourlySpec
+	outlookDays int
+	commute     []commuteWindow
+	// umbrellaAt is the probability of precipitation, in percent, from which
+	// an umbrella is needed.
+	umbrellaAt float64
+	airQuality bool
+	// warnAt is the level from which air quality readings are warned about;
+	// LevelLow never warns.
+	warnAt Level
+}
+
+var defaultForecast = weatherForecast{
+	hourly: HourlySpec{From: 7, To: 19, Every: 3},
+	commute: []commuteWindow{
+		{start: 7 * time.Hour, end: 9 * time.Hour},
+		{start: 17 * time.Hour, end: 19 * time.Hour},
+	},
+	umbrellaAt: 40,
+}
+
+// WeatherLocation is a place a weather source reports on, given by its
+// coordinates or by a name to look up.
+type WeatherLocation struct {
+	Name      string  `yaml:"name"`
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+	// Location is a place name like "Hameln, DE", resolved to coordinates
+	// through the geocoder.
+	Location string `yaml:"location"`
+	// Units overrides the source's unit system for this location.
+	Units string `yaml:"units"`
+
+	// timezone is the place's time zone, if its name was resolved.
+	timezone string
+}
+
+// validate checks the location; its problems are reported at the option key,
+// or at the shorthand keys if key is empty.
+func (l WeatherLocation) validate(key string) []error {
+	latKey, lonKey, locKey := key, key, key
+	if key == "" {
+		latKey, lonKey, locKey = "latitude", "longitude", "location"
+	}
+	hasCoords := l.Latitude != 0 || l.Longitude != 0
+	switch {
+	case l.Location != "" && hasCoords:
+		return []error{optionErrorf(locKey, "set either a location or coordinates%s, not both", forName(cmp.Or(l.Name, l.Location)))}
+	case l.Location == "" && !hasCoords:
+		return []error{optionErrorf(latKey, "a location, or latitude and longitude, is needed%s", forName(l.Name))}
+	}
+	var errs []error
+	if l.Latitude < -90 || l.Latitude > 90 {
+		errs = append(errs, optionErrorf(latKey, "latitude %g%s is out of range (-90 to 90)", l.Latitude, forName(l.Name)))
+	}
+	if l.Longitude < -180 || l.Longitude > 180 {
+		errs = append(errs, optionErrorf(lonKey, "longitude %g%s is out of range (-180 to 180)", l.Longitude, forName(l.Name)))
+	}
+	if _, ok := weatherUnits[l.Units]; key != "" && l.Units != "" && !ok {
+		errs = append(errs, optionErrorf(key, "unknown units %q%s (want %s or %s)", l.Units, forName(l.Name), UnitsMetric, UnitsImperial))
+	}
+	return errs
+}
+
+// forName returns ` for "name"` to name the location a message is about, or
+// "" for a location without a name.
+func forName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" for %q", name)
+}
+
+type weatherOptions struct {
+	// Latitude, Longitude or Location, and Name, are shorthand for a single
+	// location.
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+	Location  string  `yaml:"location"`
+	Name      string  `yaml:"name"`
+	// Locations lists the places to report on, shown in this order.
+	Locations []WeatherLocation `yaml:"locations"`
+	// Units is "metric" (default) or "imperial".
+	Units string `yaml:"units"`
+	// Hourly selects the hours of the hourly strip; default 07:00 to 19:00
+	// every 3 hours.
+	Hourly HourlySpec `yaml:"hourly"`
+	// OutlookDays adds the forecast of that many following days, 3 to 7.
+	OutlookDays int `yaml:"outlook_days"`
+	// Commute lists the spans, like "07:00-09:00", the umbrella line looks
+	// at; default 07:00-09:00 and 17:00-19:00.
+	Commute []string `yaml:"commute"`
+	// UmbrellaThreshold is the probability of precipitation in percent from
+	// which an umbrella is advised; default 40.
+	UmbrellaThreshold float64 `yaml:"umbrella_threshold"`
+	// AirQuality adds the air quality, UV index and pollen.
+	AirQuality bool `yaml:"air_quality"`
+	// AirQualityWarning is the level, like "high", from which air quality
+	// readings are shown in a warning banner; off if empty.
+	AirQualityWarning string `yaml:"air_quality_warning"`
+}
+
+// locations returns the configured locations, each with its unit system.
+func (o weatherOptions) locations() []WeatherLocation {
+	locs := slices.Clone(o.Locations)
+	if len(locs) == 0 && (o.Latitude != 0 || o.Longitude != 0 || o.Location != "") {
+		locs = []WeatherLocation{{Name: o.Name, Latitude: o.Latitude, Longitude: o.Longitude, Location: o.Location}}
+	}
+	for i := range locs {
+		if locs[i].Units == "" {
+			locs[i].Units = o.Units
+		}
+		if locs[i].Units == "" {
+			locs[i].Units = UnitsMetric
+		}
+	}
+	return locs
+}
+
+func (o weatherOptions) validate() error {
+	var errs []error
+	key := "locations"
+	if len(o.Locations) == 0 {
+		key = ""
+	}
+	locs := o.locations()
+	if len(locs) == 0 {
+		errs = append(errs, optionErrorf("latitude", "weather source needs a location, latitude and longitude, or a list of locations"))
+	}
+	if len(o.Locations) > 0 && (o.Latitude != 0 || o.Longitude != 0 || o.Location != "") {
+		errs = append(errs, optionErrorf("locations", "set either a single location or locations, not both"))
+	}
+	for _, l := range locs {
+		if key != "" && l.Units == o.Units {
+			// An unknown source-wide unit system is reported once below.
+			l.Units = ""
+		}
+		errs = append(errs, l.validate(key)...)
+	}
+	if _, ok := weatherUnits[o.Units]; o.Units != "" && !ok {
+		errs = append(errs, optionErrorf("units", "unknown units %q (want %s or %s)", o.Units, UnitsMetric, UnitsImperial))
+	}
+	if h := o.forecast().hourly; h.From < 0 || h.To > 23 || h.From > h.To || h.Every < 1 {
+		errs = append(errs, optionErrorf("hourly", "want hours from 0 to 23 with from <= to and every >= 1, got %d to %d every %d", h.From, h.To, h.Every))
+	}
+	if o.OutlookDays != 0 && (o.OutlookDays < 3 || o.OutlookDays > 7) {
+		errs = append(errs, optionErrorf("outlook_days", "must be between 3 and 7, got %d", o.OutlookDays))
+	}
+	for _, c := range o.Commute {
+		if _, err := parseCommuteWindow(c); err != nil {
+			errs = append(errs, optionErrorf("commute", "%v", err))
+		}
+	}
+	if o.UmbrellaThreshold < 0 || o.UmbrellaThreshold > 100 {
+		errs = append(errs, optionErrorf("umbrella_threshold", "must be a percentage, got %g", o.UmbrellaThreshold))
+	}
+	if o.AirQualityWarning != "" {
+		if l, ok := parseLevel(o.AirQualityWarning); !ok || l == LevelLow {
+			errs = append(errs, optionErrorf("air_quality_warning", "unknown level %q (want moderate, high, very_high or extreme)", o.AirQualityWarning))
+		}
+		if !o.AirQuality {
+			errs = append(errs, optionErrorf("air_quality_warning", "needs air_quality: true"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolve looks up the coordinates of every location given by name, and
+// replaces the shorthand with the resolved list of locations. Unknown and
+// ambiguous names are errors; a name the geocoder could not be reached for is
+// left to be looked up when the weather is fetched.
+func (o *weatherOptions) resolve(ctx context.Context, env Env) error {
+	key := "locations"
+	if len(o.Locations) == 0 {
+		key = "location"
+	}
+	locs := o.locations()
+	var errs []error
+	for i, l := range locs {
+		resolved, err := l.resolved(ctx, env.Geocoder)
+		var unreachable *UnreachableError
+		switch {
+		case errors.As(err, &unreachable):
+			slog.WarnContext(ctx, "Could not look up weather location, trying again on fetch", "location", l.Location, "error", err)
+		case err != nil:
+			errs = append(errs, optionErrorf(key, "%v", err))
+		default:
+			locs[i] = resolved
+		}
+	}
+	o.Locations = locs
+	o.Latitude, o.Longitude, o.Location, o.Name = 0, 0, "", ""
+	return errors.Join(errs...)
+}
+
+// resolved returns the location with the coordinates of its name, if it is
+// given by name. g may be nil for locations given by coordinates.
+func (l WeatherLocation) resolved(ctx context.Context, g *Geocoder) (WeatherLocation, error) {
+	if l.Location == "" {
+		return l, nil
+	}
+	if g == nil {
+		return l, fmt.Errorf("cannot look up %q without a geocoder", l.Location)
+	}
+	p, err := g.Resolve(ctx, l.Location)
+	if err != nil {
+		return l, err
+	}
+	l.Latitude, l.Longitude, l.timezone = p.Latitude, p.Longitude, p.Timezone
+	if l.Name == "" {
+		l.Name = p.Name
+	}
+	l.Location = ""
+	return l, nil
+}
+
+// forecast returns the forecast selection of the options, with defaults for
+// whatever they leave unset. Invalid commute windows are skipped; validate
+// reports them.
+func (o weatherOptions) forecast() weatherForecast {
+	f := defaultForecast
+	if o.Hourly != (HourlySpec{}) {
+		f.hourly = o.Hourly
+		if f.hourly.Every == 0 {
+			f.hourly.Every = defaultForecast.hourly.Every
+		}
+	}
+	f.outlookDays = o.OutlookDays
+	if len(o.Commute) > 0 {
+		f.commute = nil
+		for _, c := range o.Commute {
+			if w, err := parseCommuteWindow(c); err == nil {
+				f.commute = append(f.commute, w)
+			}
+		}
+	}
+	if o.UmbrellaThreshold > 0 {
+		f.umbrellaAt = o.UmbrellaThreshold
+	}
+	f.airQuality = o.AirQuality
+	f.warnAt, _ = parseLevel(o.AirQualityWarning)
+	return f
+}
+
+func init() {
+	Register(TypeWeather, func(env Env, o weatherOptions) (Fetcher, error) {
+		w := NewWeather(env.Client, o.locations())
+		w.geocoder = env.Geocoder
+		w.forecast = o.forecast()
+		return w, nil
+	})
+}
+
+// Weather fetches the forecast of one or more locations from Open-Meteo.
 type Weather struct {
 	client    *http.Client
-	latitude  float64
-	longitude float64
-	location  string
-	baseURL   string
+	locations []WeatherLocation
+	// geocoder looks up the locations whose names could not be resolved on
+	// start because it was unreachable.
+	geocoder      *Geocoder
+	forecast      weatherForecast
+	baseURL       string
+	airQualityURL string
 }
 
-func NewWeather(client *http.Client, lat, lon float64, location string) *Weather {
-	return &Weather{client: client, latitude: lat, longitude: lon, location: location, baseURL: "https://api.open-meteo.com/v1/forecast"}
+// NewWeather returns a fetcher for the given locations. Locations without
+// units use the metric system.
+func NewWeather(client *http.Client, locations []WeatherLocation) *Weather {
+	return &Weather{
+		client:        client,
+		locations:     locations,
+		forecast:      defaultForecast,
+		baseURL:       "https://api.open-meteo.com/v1/forecast",
+		airQualityURL: "https://air-quality-api.open-meteo.com/v1/air-quality",
+	}
 }
 
 func (w *Weather) Name() string { return "Weather" }
 
+// Fetch fetches every location concurrently. Locations that fail are kept,
+// with only their name and the error; only if all fail does the fetch fail.
 func (w *Weather) Fetch(ctx context.Context) (any, error) {
-	url := fmt.Sprintf(
+	type locationResult struct {
+		data WeatherData
+		err  error
+	}
+
+	var wg sync.WaitGroup
+	results := make([]locationResult, len(w.locations))
+
+	for i, loc := range w.locations {
+		wg.Add(1)
+		go func(idx int, loc WeatherLocation) {
+			defer wg.Done()
+			data, err := w.fetchLocation(ctx, loc)
+			results[idx] = locationResult{data: data, err: err}
+		}(i, loc)
+	}
+
+	wg.Wait()
+
+	all := make([]WeatherData, len(results))
+	var firstErr error
+	failed := 0
+	for i, res := range results {
+		if res.err != nil {
+			name := cmp.Or(w.locations[i].Name, w.locations[i].Location)
+			slog.WarnContext(ctx, "Failed to fetch weather location", "location", name, "error", res.err)
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			failed++
+			all[i] = WeatherData{Location: name, Error: res.err.Error()}
+			continue
+		}
+		all[i] = res.data
+	}
+
+	if failed == len(all) && firstErr != nil {
+		return nil, firstErr
+	}
+	return all, nil
+}
+
+func (w *Weather) fetchLocation(ctx context.Context, loc WeatherLocation) (WeatherData, error) {
+	loc, err := loc.resolved(ctx, w.geocoder)
+	if err != nil {
+		return WeatherData{}, err
+	}
+	units := loc.Units
+	if _, ok := weatherUnits[units]; !ok {
+		units = UnitsMetric
+	}
+	timezone := loc.timezone
+	if timezone == "" {
+		timezone = "auto"
+	}
+	reqURL := fmt.Sprintf(
 		w.baseURL+"?latitude=%.4f&longitude=%.4f"+
-			"&hourly=temperature_2m,weather_code"+
-			"&daily=temperature_2m_max,temperature_2m_min,precipitation_probability_max"+
-			"&timezone=auto&forecast_days=1",
-		w.latitude, w.longitude,
+			"&current=temperature_2m,weather_code,wind_speed_10m"+
+			"&hourly=temperature_2m,weather_code,precipitation_probability"+
+			"&daily=weather_code,temperature_2m_max,temperature_2m_min,precipitation_probability_max,precipitation_sum,sunrise,sunset,daylight_duration"+
+			"&timezone=%s&past_days=1&forecast_days=%d%s",
+		loc.Latitude, loc.Longitude, url.QueryEscape(timezone), 1+w.forecast.outlookDays, unitParams[units],
 	)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return WeatherData{}, fmt.Errorf("creating request: %w", err)
 	}
 
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetching weather: %w", err)
+		return WeatherData{}, fmt.Errorf("fetching weather: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Open-Meteo API returned status %d", resp.StatusCode)
+		return WeatherData{}, statusError(resp, "Open-Meteo API returned status %d", resp.StatusCode)
 	}
 
 	var result openMeteoResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decoding weather response: %w", err)
+		return WeatherData{}, fmt.Errorf("decoding weather response: %w", err)
+	}
+
+	data := WeatherData{
+		Temperature: result.Current.Temperature,
+		WeatherCode: result.Current.WeatherCode,
+		WindSpeed:   result.Current.WindSpeed,
+		Description: weatherDescription(result.Current.WeatherCode),
+		Location:    loc.Name,
+		Units:       weatherUnits[units],
 	}
 
-	// Find the midday (12:00) index in the hourly data
-	middayIdx := 12 // hourly data starts at 00:00, index 12 = 12:00
-	for i, t := range result.Hourly.Time {
-		if len(t) >= 13 && t[11:13] == "12" {
-			middayIdx = i
-			break
+	// The daily forecast starts with yesterday, to compare day lengths.
+	first := result.today()
+	data.Sky = result.sky(first, time.Now())
+	days := result.dailyForecast()[first:]
+	if len(days) > 0 {
+		data.HighTemp = days[0].HighTemp
+		data.LowTemp = days[0].LowTemp
+		data.Precipitation = days[0].Precipitation
+		data.PrecipitationSum = days[0].PrecipitationSum
+		data.Outlook = days[1:min(len(days), 1+w.forecast.outlookDays)]
+	}
+
+	var today []HourlyWeather
+	for _, h := range result.hourlyForecast() {
+		if len(days) == 0 || sameDay(h.Time, days[0].Date) {
+			today = append(today, h)
 		}
 	}
+	data.Hourly = w.forecast.strip(today)
+	data.Umbrella = w.forecast.umbrella(today)
 
-	var middayTemp float64
-	var middayCode int
-	if middayIdx < len(result.Hourly.Temperature) {
-		middayTemp = result.Hourly.Temperature[middayIdx]
+	if w.forecast.airQuality {
+		// The forecast is still worth sending without the air quality.
+		aq, err := w.fetchAirQuality(ctx, loc, timezone, w.forecast.warnAt)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch air quality", "location", loc.Name, "error", err)
+		}
+		data.AirQuality = aq
 	}
-	if middayIdx < len(result.Hourly.WeatherCode) {
-		middayCode = result.Hourly.WeatherCode[middayIdx]
+
+	return data, nil
+}
+
+// today returns the index of today in the daily forecast: the first day not
+// before the date of the current conditions, or 0 if that is unknown.
+func (r *openMeteoResponse) today() int {
+	date, _, ok := strings.Cut(r.Current.Time, "T")
+	if !ok {
+		return 0
+	}
+	for i, d := range r.Daily.Time {
+		if d >= date {
+			return i
+		}
 	}
+	return 0
+}
 
-	data := WeatherData{
-		Temperature: middayTemp,
-		WeatherCode: middayCode,
-		Description: weatherDescription(middayCode),
-		Location:    w.location,
+// location returns the time zone the response's times are in.
+func (r *openMeteoResponse) location() *time.Location {
+	return time.FixedZone(r.Timezone, r.UTCOffset)
+}
+
+func (r *openMeteoResponse) hourlyForecast() []HourlyWeather {
+	h := r.Hourly
+	var hours []HourlyWeather
+	for i, ts := range h.Time {
+		t, err := time.ParseInLocation("2006-01-02T15:04", ts, r.location())
+		if err != nil {
+			continue
+		}
+		hour := HourlyWeather{Time: t}
+		if i < len(h.Temperature) {
+			hour.Temperature = h.Temperature[i]
+		}
+		if i < len(h.WeatherCode) {
+			hour.WeatherCode = h.WeatherCode[i]
+		}
+		if i < len(h.Precipitation) {
+			hour.Precipitation = h.Precipitation[i]
+		}
+		hour.Description = weatherDescription(hour.WeatherCode)
+		hours = append(hours, hour)
 	}
+	return hours
+}
 
-	if len(result.Daily.TemperatureMax) > 0 {
-		data.HighTemp = result.Daily.TemperatureMax[0]
+func (r *openMeteoResponse) dailyForecast() []DailyWeather {
+	d := r.Daily
+	// Without dates, the first entry is still taken to be today.
+	n := max(len(d.Time), len(d.TemperatureMax))
+	days := make([]DailyWeather, n)
+	for i := range days {
+		day := &days[i]
+		if i < len(d.Time) {
+			day.Date, _ = time.ParseInLocation(time.DateOnly, d.Time[i], r.location())
+		}
+		if i < len(d.TemperatureMax) {
+			day.HighTemp = d.TemperatureMax[i]
+		}
+		if i < len(d.TemperatureMin) {
+			day.LowTemp = d.TemperatureMin[i]
+		}
+		if i < len(d.Precipitation) {
+			day.Precipitation = d.Precipitation[i]
+		}
+		if i < len(d.PrecipSum) {
+			day.PrecipitationSum = d.PrecipSum[i]
+		}
+		if i < len(d.WeatherCode) {
+			day.WeatherCode = d.WeatherCode[i]
+		}
+		day.Description = weatherDescription(day.WeatherCode)
 	}
-	if len(result.Daily.TemperatureMin) > 0 {
-		data.LowTemp = result.Daily.TemperatureMin[0]
+	return days
+}
+
+func sameDay(a, b time.Time) bool {
+	if b.IsZero() {
+		return true
 	}
-	if len(result.Daily.Precipitation) > 0 {
-		data.Precipitation = result.Daily.Precipitation[0]
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// strip picks the hours of the hourly strip from today's forecast, marking
+// those in which precipitation is likely.
+func (f weatherForecast) strip(today []HourlyWeather) []HourlyWeather {
+	var strip []HourlyWeather
+	for _, h := range today {
+		hour := h.Time.Hour()
+		if h.Time.Minute() == 0 && hour >= f.hourly.From && hour <= f.hourly.To && (hour-f.hourly.From)%f.hourly.Every == 0 {
+			h.Likely = h.Precipitation >= f.umbrellaAt
+			strip = append(strip, h)
+		}
 	}
+	return strip
+}
 
-	return data, nil
+// umbrella finds the commute hour with the highest probability of
+// precipitation, or returns nil if no forecast hour is in a commute window.
+func (f weatherForecast) umbrella(today []HourlyWeather) *Umbrella {
+	var u *Umbrella
+	for _, h := range today {
+		if !slices.ContainsFunc(f.commute, func(c commuteWindow) bool { return c.contains(h.Time) }) {
+			continue
+		}
+		if u == nil || h.Precipitation > u.Chance {
+			u = &Umbrella{Chance: h.Precipitation, At: h.Time}
+		}
+	}
+	if u != nil {
+		u.Needed = u.Chance >= f.umbrellaAt
+	}
+	return u
 }
 
 func weatherDescription(code int) string {
@@ -2,9 +2,14 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestWeatherFetch(t *testing.T) {
@@ -21,7 +26,7 @@ func TestWeatherFetch(t *testing.T) {
 	}))
 	defer server.Close()
 
-	weather := NewWeather(server.Client(), 52.52, 13.405, "Berlin")
+	weather := NewWeather(server.Client(), []WeatherLocation{{Name: "Berlin", Latitude: 52.52, Longitude: 13.405}})
 	weather.baseURL = server.URL
 
 	result, err := weather.Fetch(context.Background())
@@ -29,10 +34,11 @@ func TestWeatherFetch(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	data, ok := result.(WeatherData)
-	if !ok {
-		t.Fatal("result is not WeatherData")
+	locations, ok := result.([]WeatherData)
+	if !ok || len(locations) != 1 {
+		t.Fatalf("expected one location, got %#v", result)
 	}
+	data := locations[0]
 
 	if data.Temperature != 18.5 {
 		t.Errorf("expected temperature 18.5, got %f", data.Temperature)
@@ -48,6 +54,413 @@ func TestWeatherFetch(t *testing.T) {
 	}
 }
 
+func TestWeatherFetchForecast(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"timezone": "Europe/Berlin",
+			"utc_offset_seconds": 3600,
+			"current": {"temperature_2m": 6.5, "weather_code": 3},
+			"hourly": {
+				"time": ["2026-03-02T06:00", "2026-03-02T07:00", "2026-03-02T08:00", "2026-03-02T10:00",
+					"2026-03-02T13:00", "2026-03-02T16:00", "2026-03-02T17:30", "2026-03-02T18:00",
+					"2026-03-02T19:00", "2026-03-03T07:00"],
+				"temperature_2m": [4, 5, 6, 8, 11, 10, 9, 8, 7, 3],
+				"weather_code": [3, 3, 3, 2, 1, 61, 61, 63, 61, 0],
+				"precipitation_probability": [0, 10, 20, 10, 5, 50, 90, 70, 40, 100]
+			},
+			"daily": {
+				"time": ["2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05"],
+				"weather_code": [63, 0, 2, 61],
+				"temperature_2m_max": [11, 12, 13, 9],
+				"temperature_2m_min": [3, 2, 4, 5],
+				"precipitation_probability_max": [90, 0, 10, 80]
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	weather := NewWeather(server.Client(), []WeatherLocation{{Name: "Hameln", Latitude: 52.1, Longitude: 9.36}})
+	weather.baseURL = server.URL
+	weather.forecast.outlookDays = 3
+
+	result, err := weather.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := result.([]WeatherData)[0]
+
+	if query.Get("forecast_days") != "4" || !strings.Contains(query.Get("hourly"), "precipitation_probability") {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	var hours []string
+	for _, h := range data.Hourly {
+		hours = append(hours, h.Time.Format("15:04"))
+	}
+	if got := strings.Join(hours, " "); got != "07:00 10:00 13:00 16:00 19:00" {
+		t.Errorf("unexpected hourly strip %s", got)
+	}
+	if h := data.Hourly[3]; h.Temperature != 10 || h.Precipitation != 50 || h.Description != "Rain" || !h.Likely {
+		t.Errorf("unexpected 16:00 forecast %+v", h)
+	}
+	if data.Hourly[0].Likely || !data.Hourly[4].Likely {
+		t.Errorf("expected rain to be likely from the umbrella threshold on, got %+v", data.Hourly)
+	}
+	if _, offset := data.Hourly[0].Time.Zone(); offset != 3600 {
+		t.Errorf("expected the forecast's time zone, got offset %d", offset)
+	}
+
+	if len(data.Outlook) != 3 || data.Outlook[0].Date.Day() != 3 || data.Outlook[2].HighTemp != 9 || data.Outlook[2].Description != "Rain" {
+		t.Errorf("unexpected outlook %+v", data.Outlook)
+	}
+	if data.HighTemp != 11 || data.Precipitation != 90 {
+		t.Errorf("unexpected daily summary %+v", data)
+	}
+
+	u := data.Umbrella
+	if u == nil || !u.Needed || u.Chance != 90 || u.At.Format("15:04") != "17:30" {
+		t.Errorf("expected an umbrella for 17:30, got %+v", u)
+	}
+}
+
+func TestWeatherFetchLocations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch q.Get("latitude") {
+		case "40.7128":
+			if q.Get("temperature_unit") != "fahrenheit" || q.Get("wind_speed_unit") != "mph" || q.Get("precipitation_unit") != "inch" {
+				t.Errorf("expected imperial units for New York, got %v", q)
+			}
+			w.Write([]byte(`{"current": {"temperature_2m": 41, "weather_code": 0, "wind_speed_10m": 9}, "daily": {"temperature_2m_max": [48], "precipitation_sum": [0.12]}}`))
+		case "52.1040":
+			if q.Has("temperature_unit") {
+				t.Errorf("expected metric units for Hameln, got %v", q)
+			}
+			w.Write([]byte(`{"current": {"temperature_2m": 5, "weather_code": 3, "wind_speed_10m": 14}, "daily": {"temperature_2m_max": [8], "precipitation_sum": [3.2]}}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	opts := weatherOptions{
+		Units: UnitsImperial,
+		Locations: []WeatherLocation{
+			{Name: "Hameln", Latitude: 52.104, Longitude: 9.357, Units: UnitsMetric},
+			{Name: "Office", Latitude: 1, Longitude: 1},
+			{Name: "New York", Latitude: 40.7128, Longitude: -74.006},
+		},
+	}
+	weather := NewWeather(server.Client(), opts.locations())
+	weather.baseURL = server.URL
+
+	result, err := weather.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := result.([]WeatherData)
+	if len(data) != 3 || data[0].Location != "Hameln" || data[1].Location != "Office" || data[2].Location != "New York" {
+		t.Fatalf("expected every location in order, got %+v", data)
+	}
+	if data[0].Error != "" || data[0].Units.Temperature != "°C" || data[0].WindSpeed != 14 || data[0].PrecipitationSum != 3.2 {
+		t.Errorf("unexpected metric forecast %+v", data[0])
+	}
+	if !strings.Contains(data[1].Error, "502") {
+		t.Errorf("expected the failed location to carry its error, got %+v", data[1])
+	}
+	if data[2].Units != (WeatherUnits{Temperature: "°F", WindSpeed: "mph", Precipitation: "in"}) || data[2].Temperature != 41 {
+		t.Errorf("unexpected imperial forecast %+v", data[2])
+	}
+
+	weather.locations = weather.locations[1:2]
+	if _, err := weather.Fetch(context.Background()); err == nil {
+		t.Error("expected an error when every location fails")
+	}
+}
+
+func TestWeatherLocationNames(t *testing.T) {
+	var lookups int
+	server := geocodingServer(t, &lookups)
+	geocoder := NewGeocoder(server.Client(), nil)
+	geocoder.baseURL = server.URL
+	env := Env{Client: http.DefaultClient, Geocoder: geocoder}
+
+	f, err := Build(TypeWeather, env, yamlOptions("location: Hameln, DE"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loc := f.(*Weather).locations[0]
+	if loc.Name != "Hameln" || loc.Latitude != 52.10 || loc.Longitude != 9.36 || loc.timezone != "Europe/Berlin" {
+		t.Errorf("unexpected location %+v", loc)
+	}
+
+	for _, opts := range []string{"location: Springfield", "location: Atlantis"} {
+		if _, err := Build(TypeWeather, env, yamlOptions(opts)); err == nil {
+			t.Errorf("expected %q to fail on build", opts)
+		}
+	}
+	if _, err := Build(TypeWeather, Env{}, yamlOptions("location: Hameln")); err == nil {
+		t.Error("expected an error building without a geocoder")
+	}
+
+	err = Validate(TypeWeather, env, yamlOptions("locations: [{name: Home, location: \"Hameln, DE\"}, {name: Office, location: Springfield}]"))
+	var opt *OptionError
+	if !errors.As(err, &opt) || opt.Key != "locations" || !strings.Contains(opt.Msg, "Springfield, Missouri, US") {
+		t.Errorf("expected the ambiguous name to be reported with its candidates, got %v", err)
+	}
+	if err := Validate(TypeWeather, Env{}, yamlOptions("location: Hameln")); err == nil {
+		t.Error("expected an error without a geocoder")
+	}
+}
+
+func TestWeatherLocationNamesOffline(t *testing.T) {
+	geocoder := NewGeocoder(http.DefaultClient, nil)
+	geocoder.baseURL = "http://127.0.0.1:0"
+	env := Env{Client: http.DefaultClient, Geocoder: geocoder}
+
+	f, err := Build(TypeWeather, env, yamlOptions("location: Hameln, DE"))
+	if err != nil {
+		t.Fatalf("expected to start without reaching the geocoder, got %v", err)
+	}
+	weather := f.(*Weather)
+	if _, err := weather.Fetch(context.Background()); err == nil {
+		t.Error("expected the fetch to fail while the geocoder is unreachable")
+	}
+
+	var lookups int
+	server := geocodingServer(t, &lookups)
+	geocoder.baseURL = server.URL
+	var query url.Values
+	forecast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"current": {"temperature_2m": 5}}`))
+	}))
+	defer forecast.Close()
+	weather.baseURL = forecast.URL
+
+	result, err := weather.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data := result.([]WeatherData)[0]; data.Location != "Hameln" {
+		t.Errorf("expected the place's name, got %q", data.Location)
+	}
+	if query.Get("latitude") != "52.1000" || query.Get("timezone") != "Europe/Berlin" {
+		t.Errorf("expected the place's coordinates and time zone, got %v", query)
+	}
+}
+
+func TestWeatherSky(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("past_days") != "1" || !strings.Contains(q.Get("daily"), "daylight_duration") {
+			t.Errorf("expected yesterday's daylight in the query, got %v", q)
+		}
+		w.Write([]byte(`{
+			"timezone": "Europe/Berlin", "utc_offset_seconds": 3600,
+			"current": {"time": "2026-03-02T08:15", "temperature_2m": 4},
+			"daily": {
+				"time": ["2026-03-01", "2026-03-02", "2026-03-03"],
+				"temperature_2m_max": [6, 9, 11],
+				"sunrise": ["2026-03-01T07:06", "2026-03-02T07:04", "2026-03-03T07:02"],
+				"sunset": ["2026-03-01T18:05", "2026-03-02T18:07", "2026-03-03T18:09"],
+				"daylight_duration": [39540.2, 39720.6, 39900]
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	weather := NewWeather(server.Client(), []WeatherLocation{{Name: "Hameln", Latitude: 52.1, Longitude: 9.36}})
+	weather.baseURL = server.URL
+	weather.forecast.outlookDays = 1
+
+	result, err := weather.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := result.([]WeatherData)[0]
+	if data.HighTemp != 9 || len(data.Outlook) != 1 || data.Outlook[0].HighTemp != 11 {
+		t.Errorf("expected yesterday to be left out of the forecast, got %+v", data)
+	}
+	sky := data.Sky
+	if sky == nil {
+		t.Fatal("expected the sky")
+	}
+	if sky.Sunrise.Format("15:04") != "07:04" || sky.Sunset.Format("15:04") != "18:07" {
+		t.Errorf("unexpected sunrise and sunset %v, %v", sky.Sunrise, sky.Sunset)
+	}
+	if sky.Daylight != 39721*time.Second || sky.YesterdayDaylight != 39540*time.Second {
+		t.Errorf("unexpected daylight %v, yesterday %v", sky.Daylight, sky.YesterdayDaylight)
+	}
+}
+
+func TestMoonPhase(t *testing.T) {
+	for _, c := range []struct {
+		at    time.Time
+		phase int
+		want  string
+	}{
+		{time.Date(2024, 1, 11, 11, 57, 0, 0, time.UTC), 0, "New moon"},
+		{time.Date(2024, 1, 18, 3, 53, 0, 0, time.UTC), 2, "First quarter"},
+		{time.Date(2024, 1, 25, 17, 54, 0, 0, time.UTC), 4, "Full moon"},
+		{time.Date(2024, 2, 2, 23, 18, 0, 0, time.UTC), 6, "Last quarter"},
+		{time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC), 3, "Waxing gibbous"},
+	} {
+		if got := moonPhase(c.at); got.Phase != c.phase || got.Name != c.want {
+			t.Errorf("%v: expected %s, got %+v", c.at, c.want, got)
+		}
+	}
+	if full := moonPhase(time.Date(2024, 1, 25, 17, 54, 0, 0, time.UTC)); full.Illumination < 99 {
+		t.Errorf("expected a fully lit moon, got %+v", full)
+	}
+}
+
+func TestWeatherAirQuality(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		case "/offline":
+			w.Write([]byte(`{"current": {"european_aqi": null, "pm2_5": null}, "hourly": {"uv_index": [1, 2]}}`))
+			return
+		case "/air":
+			if !strings.Contains(r.URL.Query().Get("hourly"), "birch_pollen") {
+				t.Errorf("expected pollen in the query, got %v", r.URL.Query())
+			}
+			w.Write([]byte(`{
+				"current": {"european_aqi": 45, "pm2_5": 8.4},
+				"hourly": {"uv_index": [0, 3.2, 6.5, null], "birch_pollen": [20, 140, null, 90], "grass_pollen": [0, 4, 2, 0], "olive_pollen": [null, null, null, null], "alder_pollen": [0, 0, 0, 0]}
+			}`))
+			return
+		}
+		w.Write([]byte(`{"current": {"temperature_2m": 14, "weather_code": 0}}`))
+	}))
+	defer server.Close()
+
+	opts := weatherOptions{Latitude: 52.1, Longitude: 9.36, AirQuality: true, AirQualityWarning: "high"}
+	weather := NewWeather(server.Client(), opts.locations())
+	weather.forecast = opts.forecast()
+	weather.baseURL = server.URL
+	weather.airQualityURL = server.URL + "/air"
+
+	result, err := weather.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	aq := result.([]WeatherData)[0].AirQuality
+	if aq == nil {
+		t.Fatal("expected air quality")
+	}
+	if aq.AQI == nil || *aq.AQI != (Reading{Value: 45, Level: LevelModerate}) || aq.PM25 == nil || aq.PM25.Level != LevelLow || aq.UVIndex != (Reading{Value: 6.5, Level: LevelHigh}) {
+		t.Errorf("unexpected readings %+v", aq)
+	}
+	want := []Pollen{{Name: "Birch", Count: 140, Level: LevelVeryHigh}, {Name: "Grass", Count: 4, Level: LevelLow}}
+	if !reflect.DeepEqual(aq.Pollen, want) {
+		t.Errorf("expected %+v, got %+v", want, aq.Pollen)
+	}
+	if strings.Join(aq.Warnings, "; ") != "UV index is high (6); Birch pollen is very high (140 grains/m³)" {
+		t.Errorf("unexpected warnings %q", aq.Warnings)
+	}
+
+	weather.airQualityURL = server.URL + "/offline"
+	result, err = weather.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if aq := result.([]WeatherData)[0].AirQuality; aq == nil || aq.AQI != nil || aq.PM25 != nil || aq.UVIndex.Value != 2 {
+		t.Errorf("expected no AQI or PM2.5 from a station without readings, got %+v", aq)
+	}
+
+	weather.airQualityURL = server.URL + "/broken"
+	result, err = weather.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("expected the forecast without air quality, got %v", err)
+	}
+	if data := result.([]WeatherData)[0]; data.Temperature != 14 || data.AirQuality != nil {
+		t.Errorf("unexpected forecast %+v", data)
+	}
+}
+
+func TestLevelScales(t *testing.T) {
+	for _, c := range []struct {
+		scale levelScale
+		value float64
+		want  Level
+	}{
+		{aqiScale, 39, LevelLow},
+		{aqiScale, 100, LevelExtreme},
+		{uvScale, 2.9, LevelLow},
+		{uvScale, 8, LevelVeryHigh},
+		{pm25Scale, 24, LevelModerate},
+		{pollenScale, 5000, LevelVeryHigh},
+	} {
+		if got := c.scale.level(c.value); got != c.want {
+			t.Errorf("level(%g) = %v, want %v", c.value, got, c.want)
+		}
+	}
+	if l, ok := parseLevel("Very_High"); !ok || l != LevelVeryHigh {
+		t.Errorf("expected very high, got %v, %v", l, ok)
+	}
+}
+
+func TestWeatherUmbrella(t *testing.T) {
+	at := func(hour int, chance float64) HourlyWeather {
+		return HourlyWeather{Time: time.Date(2026, 3, 2, hour, 0, 0, 0, time.UTC), Precipitation: chance}
+	}
+	f := defaultForecast
+
+	if u := f.umbrella([]HourlyWeather{at(8, 30), at(12, 100), at(18, 20)}); u == nil || u.Needed || u.Chance != 30 {
+		t.Errorf("expected no umbrella for a dry commute, got %+v", u)
+	}
+	if u := f.umbrella([]HourlyWeather{at(12, 100)}); u != nil {
+		t.Errorf("expected no umbrella without commute hours, got %+v", u)
+	}
+	f.umbrellaAt = 25
+	if u := f.umbrella([]HourlyWeather{at(8, 30)}); u == nil || !u.Needed {
+		t.Errorf("expected an umbrella above the threshold, got %+v", u)
+	}
+}
+
+func TestWeatherOptions(t *testing.T) {
+	if err := Validate(TypeWeather, Env{}, yamlOptions("latitude: 52.1\nlongitude: 9.4\noutlook_days: 5\ncommute: [\"06:30-08:00\"]")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Validate(TypeWeather, Env{}, yamlOptions("units: imperial\nlocations: [{name: Home, latitude: 52.1, longitude: 9.4}, {name: Office, latitude: 52.5, longitude: 13.4, units: metric}]")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, opts := range []string{
+		"latitude: 52.1\nlongitude: 9.4\noutlook_days: 10",
+		"latitude: 52.1\nlongitude: 9.4\nunits: kelvin",
+		"locations: [{name: Home, latitude: 52.1, longitude: 9.4}, {name: Office}]",
+		"latitude: 52.1\nlongitude: 9.4\nlocations: [{name: Home, latitude: 52.1, longitude: 9.4}]",
+		"location: Hameln\nlatitude: 52.1\nlongitude: 9.4",
+		"latitude: 52.1\nlongitude: 9.4\nair_quality: true\nair_quality_warning: low",
+		"latitude: 52.1\nlongitude: 9.4\nair_quality_warning: high",
+		"latitude: 52.1\nlongitude: 9.4\ncommute: [\"9 to 5\"]",
+		"latitude: 52.1\nlongitude: 9.4\nhourly: {from: 19, to: 7}",
+	} {
+		if err := Validate(TypeWeather, Env{}, yamlOptions(opts)); err == nil {
+			t.Errorf("expected error for %q", opts)
+		}
+	}
+}
+
+func TestWeatherLocationMessages(t *testing.T) {
+	for opts, want := range map[string]string{
+		"latitude: 200\nlongitude: 9.4":                                "latitude: latitude 200 is out of range (-90 to 90)",
+		"locations: [{name: Home, latitude: 200, longitude: 9.4}]":     `locations: latitude 200 for "Home" is out of range (-90 to 90)`,
+		"locations: [{latitude: 52.1, longitude: 9.4, units: kelvin}]": `locations: unknown units "kelvin" (want metric or imperial)`,
+		"locations: [{name: Home, location: Hameln, latitude: 52.1}]":  `locations: set either a location or coordinates for "Home", not both`,
+	} {
+		if err := Validate(TypeWeather, Env{}, yamlOptions(opts)); err == nil || err.Error() != want {
+			t.Errorf("expected %q for %q, got %v", want, opts, err)
+		}
+	}
+}
+
 func TestWeatherDescription(t *testing.T) {
 	tests := []struct {
 		code int
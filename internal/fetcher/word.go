@@ -0,0 +1,30 @@
+package fetcher
+
+import (
+	"regexp"
+	"unicode"
+)
+
+// nonWord matches a character that cannot be part of a word. RE2's \b only
+// knows ASCII, which would break keywords like "Überwachung".
+const nonWord = `[^\p{L}\p{N}_]`
+
+// WordPattern matches kw as a whole word, ignoring case. Word boundaries are
+// only required where kw itself starts or ends with a word character, so
+// "C++" and ".NET" work too. Filters and interest profiles both match their
+// keywords against ItemInfo with it.
+func WordPattern(kw string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(kw)
+	runes := []rune(kw)
+	if len(runes) > 0 && isWordChar(runes[0]) {
+		expr = `(?:^|` + nonWord + `)` + expr
+	}
+	if len(runes) > 0 && isWordChar(runes[len(runes)-1]) {
+		expr += `(?:$|` + nonWord + `)`
+	}
+	return regexp.MustCompile("(?i)" + expr)
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
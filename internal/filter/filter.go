@@ -0,0 +1,204 @@
+// Package filter hides items from the digest by keyword, pattern, domain or
+// author rules, globally or per source, and keeps a log of what it hid.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+// Hidden is an item a filter kept out of the digest.
+type Hidden struct {
+	Source string
+	Title  string
+	URL    string
+	Reason string
+}
+
+// ruleSet is a compiled config.FilterRules.
+type ruleSet struct {
+	include []textRule
+	exclude []textRule
+	domains []string
+	authors []string
+}
+
+// textRule is a keyword or pattern; label is how reasons show it.
+type textRule struct {
+	re    *regexp.Regexp
+	label string
+}
+
+// Engine applies the global rules and each source's own rules.
+type Engine struct {
+	global    *ruleSet
+	perSource map[string]*ruleSet
+}
+
+// New compiles the global rules and the rules of every source. It fails on
+// invalid regular expressions.
+func New(global config.FilterRules, sources []config.SourceConfig) (*Engine, error) {
+	e := &Engine{perSource: make(map[string]*ruleSet)}
+
+	var err error
+	if e.global, err = compile(global); err != nil {
+		return nil, fmt.Errorf("filters: %w", err)
+	}
+	for _, src := range sources {
+		rs, err := compile(src.Filters)
+		if err != nil {
+			return nil, fmt.Errorf("source %q (line %d) filters: %w", src.ID, src.Line, err)
+		}
+		if rs != nil {
+			e.perSource[src.ID] = rs
+		}
+	}
+	return e, nil
+}
+
+// Screens returns a per-source screen that rejects the items the rules hide
+// and records them in log. Sources without any rules get no screen.
+func (e *Engine) Screens(log *Log) func(src fetcher.Source) fetcher.Screen {
+	return func(src fetcher.Source) fetcher.Screen {
+		sets := make([]*ruleSet, 0, 2)
+		for _, rs := range []*ruleSet{e.global, e.perSource[src.ID]} {
+			if rs != nil {
+				sets = append(sets, rs)
+			}
+		}
+		if len(sets) == 0 {
+			return nil
+		}
+
+		return func(item fetcher.Item) bool {
+			info := item.Info()
+			for _, rs := range sets {
+				if reason, hide := rs.match(info); hide {
+					log.add(item.Key(), Hidden{Source: src.ID, Title: title(info), URL: info.URL, Reason: reason})
+					return false
+				}
+			}
+			return true
+		}
+	}
+}
+
+// match reports whether the rules hide the item, and why.
+func (rs *ruleSet) match(info fetcher.ItemInfo) (string, bool) {
+	text := info.Title + "\n" + info.Text
+
+	if domain := info.Domain(); domain != "" {
+		for _, d := range rs.domains {
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				return "domain " + d, true
+			}
+		}
+	}
+	for _, a := range rs.authors {
+		if info.Author != "" && strings.EqualFold(info.Author, a) {
+			return "author " + a, true
+		}
+	}
+	for _, r := range rs.exclude {
+		if r.re.MatchString(text) {
+			return "excluded " + r.label, true
+		}
+	}
+	if len(rs.include) == 0 {
+		return "", false
+	}
+	for _, r := range rs.include {
+		if r.re.MatchString(text) {
+			return "", false
+		}
+	}
+	return "no include rule matched", true
+}
+
+func compile(r config.FilterRules) (*ruleSet, error) {
+	rs := &ruleSet{}
+	for _, kw := range r.Include {
+		rs.include = append(rs.include, keyword(kw))
+	}
+	for _, kw := range r.Exclude {
+		rs.exclude = append(rs.exclude, keyword(kw))
+	}
+	for _, p := range r.IncludePatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("include pattern %q: %w", p, err)
+		}
+		rs.include = append(rs.include, textRule{re: re, label: "/" + p + "/"})
+	}
+	for _, p := range r.ExcludePatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("exclude pattern %q: %w", p, err)
+		}
+		rs.exclude = append(rs.exclude, textRule{re: re, label: "/" + p + "/"})
+	}
+	for _, d := range r.Domains {
+		rs.domains = append(rs.domains, strings.TrimPrefix(strings.ToLower(d), "www."))
+	}
+	rs.authors = append(rs.authors, r.Authors...)
+
+	if len(rs.include)+len(rs.exclude)+len(rs.domains)+len(rs.authors) == 0 {
+		return nil, nil
+	}
+	return rs, nil
+}
+
+func keyword(kw string) textRule {
+	return textRule{re: fetcher.WordPattern(kw), label: kw}
+}
+
+func title(info fetcher.ItemInfo) string {
+	if info.Title != "" {
+		return info.Title
+	}
+	const max = 80
+	if runes := []rune(info.Text); len(runes) > max {
+		return string(runes[:max]) + "…"
+	}
+	return info.Text
+}
+
+// Log collects the items hidden during one run. An item screened more than
+// once is logged once.
+type Log struct {
+	mu    sync.Mutex
+	seen  map[string]bool
+	items []Hidden
+}
+
+func (l *Log) add(key string, h Hidden) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := h.Source + "\x00" + key
+	if l.seen[k] {
+		return
+	}
+	if l.seen == nil {
+		l.seen = make(map[string]bool)
+	}
+	l.seen[k] = true
+	l.items = append(l.items, h)
+}
+
+// Items returns the hidden items in the order they were hidden.
+func (l *Log) Items() []Hidden {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Hidden(nil), l.items...)
+}
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+func source(id string) fetcher.Source {
+	return fetcher.Source{Type: "stub", ID: id, Title: id}
+}
+
+func TestRules(t *testing.T) {
+	engine, err := New(config.FilterRules{
+		Exclude:         []string{"crypto", "C++"},
+		ExcludePatterns: []string{`(?i)bitcoin|ethereum`},
+		Domains:         []string{"example.com"},
+		Authors:         []string{"Spammer"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var log Log
+	admit := engine.Screens(&log)(source("hackernews"))
+
+	tests := []struct {
+		post fetcher.HNPost
+		want bool
+	}{
+		{fetcher.HNPost{Title: "Show HN: A Go parser", URL: "https://go.dev/x"}, true},
+		{fetcher.HNPost{Title: "Crypto winter is over", URL: "https://a.org"}, false},
+		{fetcher.HNPost{Title: "Cryptography basics", URL: "https://a.org"}, true},
+		{fetcher.HNPost{Title: "Why C++ is hard", URL: "https://a.org/cpp"}, false},
+		{fetcher.HNPost{Title: "Ask HN", StoryText: "Is Bitcoin dead?", ObjectID: "1"}, false},
+		{fetcher.HNPost{Title: "News", URL: "https://blog.example.com/post"}, false},
+		{fetcher.HNPost{Title: "News", URL: "https://notexample.com/post"}, true},
+		{fetcher.HNPost{Title: "News", URL: "https://a.org/1", Author: "spammer"}, false},
+	}
+	for _, tt := range tests {
+		if got := admit(tt.post); got != tt.want {
+			t.Errorf("%q (%s): expected admit=%v, got %v", tt.post.Title, tt.post.URL, tt.want, got)
+		}
+	}
+
+	if n := len(log.Items()); n != 5 {
+		t.Errorf("expected 5 hidden items, got %d", n)
+	}
+	// Screening the same item again doesn't count it twice.
+	admit(tests[1].post)
+	if n := len(log.Items()); n != 5 {
+		t.Errorf("expected repeated items to be logged once, got %d", n)
+	}
+	if h := log.Items()[0]; h.Source != "hackernews" || h.Reason != "excluded crypto" {
+		t.Errorf("unexpected hidden item %+v", h)
+	}
+}
+
+func TestIncludeAndPerSource(t *testing.T) {
+	sources := []config.SourceConfig{
+		{ID: "reddit-de", Filters: config.FilterRules{Include: []string{"Überwachung", "Bahn"}}},
+		{ID: "hackernews"},
+	}
+	engine, err := New(config.FilterRules{}, sources)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var log Log
+	screens := engine.Screens(&log)
+	if screens(source("hackernews")) != nil {
+		t.Error("expected no screen for a source without rules")
+	}
+
+	admit := screens(source("reddit-de"))
+	if !admit(fetcher.RedditPost{Title: "Überwachung an Bahnhöfen", Permalink: "/1"}) {
+		t.Error("expected post matching an include keyword to pass")
+	}
+	if admit(fetcher.RedditPost{Title: "Wahlumfrage", Permalink: "/2"}) {
+		t.Error("expected post matching no include keyword to be hidden")
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	_, err := New(config.FilterRules{}, []config.SourceConfig{
+		{ID: "feed", Line: 12, Filters: config.FilterRules{ExcludePatterns: []string{"(unclosed"}}},
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
+
+func TestScreenOnContext(t *testing.T) {
+	engine, _ := New(config.FilterRules{Exclude: []string{"politics"}}, nil)
+	var log Log
+	ctx := fetcher.WithScreen(context.Background(), engine.Screens(&log)(source("feed")))
+
+	if fetcher.Admit(ctx, fetcher.FeedItem{Title: "Politics today", Link: "https://a.org/1"}) {
+		t.Error("expected item to be screened out")
+	}
+}
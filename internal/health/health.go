@@ -0,0 +1,181 @@
+// Package health tracks sources whose fetches keep failing and words the
+// alerts and recovery notices about them.
+package health
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+// Notice is an alert about a source that keeps failing, or the notice that it
+// works again.
+type Notice struct {
+	SourceID string
+	Title    string
+	// Recovered is set when the source worked again after an alert.
+	Recovered bool
+	// New is set on the run a failing source reached the alert threshold.
+	New bool
+	// Failures is the number of runs in a row the source failed in.
+	Failures int
+	// Since is when the first of the failures happened, and Duration how long
+	// the source has been failing, or had been failing before it recovered.
+	Since     time.Time
+	Duration  time.Duration
+	LastError string
+}
+
+// Summary describes the notice in one line.
+func (n Notice) Summary() string {
+	if n.Recovered {
+		return fmt.Sprintf("%s is working again after %s over %s.", n.Title, plural(n.Failures, "failed run"), describe(n.Duration))
+	}
+	return fmt.Sprintf("%s has failed %s in a row since %s (%s). Last error: %s",
+		n.Title, plural(n.Failures, "run"), n.Since.Format("Jan 2, 15:04"), describe(n.Duration), n.LastError)
+}
+
+// Track updates the fetch health of every fetched source and returns a notice
+// for each source that has failed at least threshold runs in a row, and for
+// each that recovered after an alert. A threshold of zero never alerts.
+// Notices stay new until Acknowledge records that they were delivered.
+func Track(store *state.Store, results []fetcher.Result, threshold int, now time.Time) ([]Notice, error) {
+	updates := make(map[string]state.Health, len(results))
+	var notices []Notice
+	for _, res := range results {
+		h := store.Health(res.ID)
+		err := res.Failed()
+
+		if err == nil {
+			if h.Alerted {
+				if h.RecoveredAt.IsZero() {
+					h.RecoveredAt = now
+					updates[res.ID] = h
+				}
+				notices = append(notices, Notice{
+					SourceID:  res.ID,
+					Title:     res.Title,
+					Recovered: true,
+					Failures:  h.Failures,
+					Since:     h.FailingSince,
+					Duration:  h.RecoveredAt.Sub(h.FailingSince),
+				})
+			} else if h != (state.Health{}) {
+				updates[res.ID] = state.Health{}
+			}
+			continue
+		}
+
+		if h.Failures == 0 {
+			h.FailingSince = now
+		}
+		// A source that fails again before its recovery was announced is
+		// still failing as far as the alert address knows.
+		h.RecoveredAt = time.Time{}
+		h.Failures++
+		h.LastError = err.Error()
+		if threshold > 0 && h.Failures >= threshold {
+			notices = append(notices, Notice{
+				SourceID:  res.ID,
+				Title:     res.Title,
+				New:       !h.Alerted,
+				Failures:  h.Failures,
+				Since:     h.FailingSince,
+				Duration:  now.Sub(h.FailingSince),
+				LastError: h.LastError,
+			})
+		}
+		updates[res.ID] = h
+	}
+
+	if len(updates) == 0 {
+		return notices, nil
+	}
+	return notices, store.SetHealth(updates)
+}
+
+// Acknowledge records that the notices were delivered: failing sources count
+// as alerted, and recovered ones as healthy. Call it only once the alert email
+// or the digest carrying the notices went out.
+func Acknowledge(store *state.Store, notices []Notice) error {
+	updates := make(map[string]state.Health, len(notices))
+	for _, n := range notices {
+		h := store.Health(n.SourceID)
+		switch {
+		case n.Recovered && !h.RecoveredAt.IsZero():
+			updates[n.SourceID] = state.Health{}
+		case !n.Recovered && h.Failures > 0:
+			h.Alerted = true
+			updates[n.SourceID] = h
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return store.SetHealth(updates)
+}
+
+// Changed reports whether any notice is news: a source that just reached the
+// threshold or one that recovered.
+func Changed(notices []Notice) bool {
+	for _, n := range notices {
+		if n.New || n.Recovered {
+			return true
+		}
+	}
+	return false
+}
+
+// Email words the notices as a short alert email.
+func Email(notices []Notice) *renderer.RenderedEmail {
+	var failing, recovered []string
+	for _, n := range notices {
+		if n.Recovered {
+			recovered = append(recovered, n.Title)
+		} else {
+			failing = append(failing, n.Title)
+		}
+	}
+
+	var subject string
+	switch {
+	case len(failing) > 0:
+		subject = "Burrow: " + strings.Join(failing, ", ") + " failing"
+	default:
+		subject = "Burrow: " + strings.Join(recovered, ", ") + " working again"
+	}
+
+	var text, htm strings.Builder
+	htm.WriteString("<ul>\n")
+	for _, n := range notices {
+		fmt.Fprintf(&text, "* %s\n", n.Summary())
+		fmt.Fprintf(&htm, "<li>%s</li>\n", html.EscapeString(n.Summary()))
+	}
+	htm.WriteString("</ul>\n")
+
+	return &renderer.RenderedEmail{Subject: subject, HTML: htm.String(), Text: text.String()}
+}
+
+// describe formats a duration coarsely, e.g. "3 days" or "5 hours".
+func describe(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return plural(int(d.Hours()/24), "day")
+	case d >= time.Hour:
+		return plural(int(d.Hours()), "hour")
+	default:
+		return plural(max(1, int(d.Minutes())), "minute")
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,135 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+func TestTrackAlertsAfterThresholdAndRecovers(t *testing.T) {
+	store, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	failed := []fetcher.Result{{ID: "readwise", Title: "Readwise", Error: fmt.Errorf("HTTP 502")}}
+	ok := []fetcher.Result{{ID: "readwise", Title: "Readwise", Data: 1}}
+
+	var notices []Notice
+	for day := range 4 {
+		notices, err = Track(store, failed, 3, start.AddDate(0, 0, day))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		switch day {
+		case 0, 1:
+			if len(notices) != 0 {
+				t.Errorf("day %d: expected no notice below the threshold, got %+v", day, notices)
+			}
+		case 2:
+			if len(notices) != 1 || !notices[0].New || notices[0].Failures != 3 || notices[0].Duration != 48*time.Hour {
+				t.Errorf("day %d: expected a new alert, got %+v", day, notices)
+			}
+			if err := Acknowledge(store, notices); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		case 3:
+			if len(notices) != 1 || notices[0].New {
+				t.Errorf("day %d: expected an ongoing alert, got %+v", day, notices)
+			}
+		}
+	}
+	if Changed(notices) {
+		t.Error("expected an ongoing alert not to count as a change")
+	}
+
+	notices, err = Track(store, ok, 3, start.AddDate(0, 0, 4))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notices) != 1 || !notices[0].Recovered || notices[0].Failures != 4 {
+		t.Fatalf("expected a recovery notice, got %+v", notices)
+	}
+	if err := Acknowledge(store, notices); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h := store.Health("readwise"); h != (state.Health{}) {
+		t.Errorf("expected the health to be reset, got %+v", h)
+	}
+
+	notices, _ = Track(store, ok, 3, start.AddDate(0, 0, 5))
+	if len(notices) != 0 {
+		t.Errorf("expected no second recovery notice, got %+v", notices)
+	}
+}
+
+func TestTrackRepeatsUndeliveredNotices(t *testing.T) {
+	store, _ := state.Open(t.TempDir())
+	start := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	failed := []fetcher.Result{{ID: "readwise", Title: "Readwise", Error: fmt.Errorf("HTTP 502")}}
+	ok := []fetcher.Result{{ID: "readwise", Title: "Readwise", Data: 1}}
+
+	for day := range 2 {
+		notices, _ := Track(store, failed, 1, start.AddDate(0, 0, day))
+		if len(notices) != 1 || !notices[0].New {
+			t.Errorf("day %d: expected the alert to stay new until delivered, got %+v", day, notices)
+		}
+	}
+	notices, _ := Track(store, failed, 1, start.AddDate(0, 0, 2))
+	Acknowledge(store, notices)
+
+	for day := 3; day < 5; day++ {
+		notices, _ := Track(store, ok, 1, start.AddDate(0, 0, day))
+		if len(notices) != 1 || !notices[0].Recovered || notices[0].Duration != 72*time.Hour {
+			t.Errorf("day %d: expected the recovery notice until delivered, got %+v", day, notices)
+		}
+	}
+}
+
+func TestTrackRecoveryWithoutAlertIsQuiet(t *testing.T) {
+	store, _ := state.Open(t.TempDir())
+	now := time.Now()
+
+	Track(store, []fetcher.Result{{ID: "hn", Error: fmt.Errorf("timeout")}}, 3, now)
+	notices, _ := Track(store, []fetcher.Result{{ID: "hn", Data: 1}}, 3, now.Add(time.Hour))
+
+	if len(notices) != 0 {
+		t.Errorf("expected no notice, got %+v", notices)
+	}
+}
+
+func TestTrackCountsCachedFallbackAsFailure(t *testing.T) {
+	store, _ := state.Open(t.TempDir())
+
+	res := fetcher.Result{ID: "weather", Data: 1, CachedAt: time.Now(), FetchError: fmt.Errorf("HTTP 502")}
+	notices, _ := Track(store, []fetcher.Result{res}, 1, time.Now())
+
+	if len(notices) != 1 || notices[0].LastError != "HTTP 502" {
+		t.Errorf("expected an alert, got %+v", notices)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	email := Email([]Notice{
+		{Title: "Readwise", Failures: 3, Since: time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC), Duration: 50 * time.Hour, LastError: "HTTP <502>"},
+		{Title: "Weather", Recovered: true, Failures: 1, Duration: time.Hour},
+	})
+
+	if email.Subject != "Burrow: Readwise failing" {
+		t.Errorf("unexpected subject %q", email.Subject)
+	}
+	want := "Readwise has failed 3 runs in a row since Mar 1, 07:00 (2 days). Last error: HTTP <502>"
+	if !strings.Contains(email.Text, want) {
+		t.Errorf("expected %q in %q", want, email.Text)
+	}
+	if !strings.Contains(email.Text, "Weather is working again after 1 failed run over 1 hour.") {
+		t.Errorf("unexpected recovery line in %q", email.Text)
+	}
+	if !strings.Contains(email.HTML, "HTTP &lt;502&gt;") {
+		t.Errorf("expected the HTML to be escaped, got %q", email.HTML)
+	}
+}
@@ -0,0 +1,166 @@
+// Package logging sets up the structured logger: a text or JSON handler that
+// tags every line with the ID of the digest run it belongs to and keeps
+// secrets out of the output.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+)
+
+// redacted replaces secrets in log output.
+const redacted = "[REDACTED]"
+
+// minSecretLen keeps very short values, which would match all over the log,
+// from being treated as secrets.
+const minSecretLen = 6
+
+type runIDKey struct{}
+
+// WithRunID returns a context whose log lines carry the given run ID.
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// RunID returns the run ID carried by ctx, or "".
+func RunID(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// NewRunID returns a short random ID for a digest run.
+func NewRunID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// New returns a logger writing to w in the configured format and level. The
+// given secrets are redacted from messages and attributes, as are tokens in
+// URL query strings and authorization headers.
+func New(w io.Writer, cfg config.LogConfig, secrets []string) (*slog.Logger, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("log.level: %w", err)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch cfg.Format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log.format %q (want text or json)", cfg.Format)
+	}
+	return slog.New(&handler{next: h, redactor: NewRedactor(secrets)}), nil
+}
+
+// handler adds the run ID from the context and redacts every record before
+// passing it on.
+type handler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.redactor.Redact(r.Message), r.PC)
+	if id := RunID(ctx); id != "" {
+		out.AddAttrs(slog.String("run_id", id))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactor.attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	red := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		red[i] = h.redactor.attr(a)
+	}
+	return &handler{next: h.next.WithAttrs(red), redactor: h.redactor}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+var (
+	// sensitiveParam matches the value of URL query parameters whose name
+	// suggests a credential, like ?api_key=… or &access_token=….
+	sensitiveParam = regexp.MustCompile(`(?i)([?&][\w.-]*(?:token|key|secret|password|passwd|signature|sig)[\w.-]*=)[^&\s"']+`)
+	// authHeader matches credentials in Authorization-style header values.
+	authHeader = regexp.MustCompile(`(?i)\b(bearer|basic|client-id)\s+[\w.~+/=-]+`)
+)
+
+// Redactor removes secrets from text.
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor returns a redactor for the given secret values. Empty and very
+// short values are ignored.
+func NewRedactor(secrets []string) *Redactor {
+	var keep []string
+	for _, s := range secrets {
+		if len(s) >= minSecretLen && !slices.Contains(keep, s) {
+			keep = append(keep, s)
+		}
+	}
+	// Longest first, so a secret containing another is replaced whole.
+	slices.SortFunc(keep, func(a, b string) int { return len(b) - len(a) })
+	return &Redactor{secrets: keep}
+}
+
+// Redact returns s with every known secret, credential query parameter and
+// authorization header value replaced.
+func (r *Redactor) Redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	s = sensitiveParam.ReplaceAllString(s, "${1}"+redacted)
+	return authHeader.ReplaceAllString(s, "${1} "+redacted)
+}
+
+// attr redacts a log attribute. Errors and other values printed as text are
+// turned into redacted strings.
+func (r *Redactor) attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		red := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			red[i] = r.attr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(red...)}
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.Redact(x.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.Redact(x.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+)
+
+func TestRedact(t *testing.T) {
+	r := NewRedactor([]string{"s3cr3t-token", "", "abc"})
+
+	for in, want := range map[string]string{
+		"auth failed for s3cr3t-token":                    "auth failed for [REDACTED]",
+		"GET https://api.example.com/v1?api_key=xyz&q=go": "GET https://api.example.com/v1?api_key=[REDACTED]&q=go",
+		"GET /feed?access_token=xyz":                      "GET /feed?access_token=[REDACTED]",
+		"Authorization: Bearer eyJhbGciOi.payload":        "Authorization: Bearer [REDACTED]",
+		"abc stays, it is too short to be a secret":       "abc stays, it is too short to be a secret",
+	} {
+		if got := r.Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoggerAddsRunIDAndRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LogConfig{Format: "json", Level: "debug"}, []string{"s3cr3t-token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := WithRunID(context.Background(), "a1b2c3d4")
+	logger.DebugContext(ctx, "Fetch failed",
+		"error", fmt.Errorf("GET https://example.com/?token=s3cr3t-token: HTTP 401"),
+		slog.Group("request", "auth", "Bearer s3cr3t-token"))
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if line["run_id"] != "a1b2c3d4" {
+		t.Errorf("expected the run ID, got %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "s3cr3t") {
+		t.Errorf("expected the secret to be redacted, got %s", buf.String())
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, config.LogConfig{Format: "xml"}, nil); err == nil {
+		t.Error("expected an unknown format error")
+	}
+	if _, err := New(&bytes.Buffer{}, config.LogConfig{Level: "loud"}, nil); err == nil {
+		t.Error("expected an unknown level error")
+	}
+
+	var buf bytes.Buffer
+	logger, _ := New(&buf, config.LogConfig{Level: "warn"}, nil)
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("expected info to be dropped at warn level, got %s", buf.String())
+	}
+}
@@ -1,55 +1,30 @@
 package mailer
 
 import (
+	"context"
 	"fmt"
-	"time"
 
+	"github.com/janiskrasemann/burrow/internal/config"
 	"github.com/janiskrasemann/burrow/internal/renderer"
-	"github.com/resend/resend-go/v3"
 )
 
-type Mailer struct {
-	from        string
-	to          string
-	client      *resend.Client
-	headerImage []byte
-}
+// headerImageCID is the Content-ID the HTML template references the masthead
+// image by.
+const headerImageCID = "header-image"
 
-func New(from, to, apiKey string, headerImage []byte) *Mailer {
-	return &Mailer{
-		from:        from,
-		to:          to,
-		client:      resend.NewClient(apiKey),
-		headerImage: headerImage,
-	}
+// Mailer delivers a rendered digest to one recipient.
+type Mailer interface {
+	Send(ctx context.Context, to string, email *renderer.RenderedEmail) error
 }
 
-func (m *Mailer) Send(email *renderer.RenderedEmail) error {
-	subject := fmt.Sprintf("Burrow Digest — %s", time.Now().Format("Jan 2, 2006"))
-
-	params := &resend.SendEmailRequest{
-		From:    m.from,
-		To:      []string{m.to},
-		Subject: subject,
-		Html:    email.HTML,
-		Text:    email.Text,
-	}
-
-	if len(m.headerImage) > 0 {
-		params.Attachments = []*resend.Attachment{
-			{
-				Content:   m.headerImage,
-				Filename:  "header.jpg",
-				ContentId: "header-image",
-			},
-		}
-	}
-
-	sent, err := m.client.Emails.Send(params)
-	if err != nil {
-		return fmt.Errorf("sending email via resend: %w", err)
+// New returns the mailer for the configured email provider.
+func New(cfg config.EmailConfig, headerImage []byte) (Mailer, error) {
+	switch cfg.Provider {
+	case "", "resend":
+		return NewResend(cfg.From, cfg.ResendAPIKey, headerImage), nil
+	case "smtp":
+		return NewSMTP(cfg.From, cfg.SMTP, headerImage)
+	default:
+		return nil, fmt.Errorf("unknown email provider %q (want resend or smtp)", cfg.Provider)
 	}
-
-	fmt.Printf("email sent: %s\n", sent.Id)
-	return nil
 }
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/janiskrasemann/burrow/internal/renderer"
+	"github.com/resend/resend-go/v3"
+)
+
+// Resend sends the digest through the Resend API.
+type Resend struct {
+	from        string
+	client      *resend.Client
+	headerImage []byte
+}
+
+func NewResend(from, apiKey string, headerImage []byte) *Resend {
+	return &Resend{
+		from:        from,
+		client:      resend.NewClient(apiKey),
+		headerImage: headerImage,
+	}
+}
+
+func (m *Resend) Send(ctx context.Context, to string, email *renderer.RenderedEmail) error {
+	params := &resend.SendEmailRequest{
+		From:    m.from,
+		To:      []string{to},
+		Subject: email.Subject,
+		Html:    email.HTML,
+		Text:    email.Text,
+	}
+
+	if len(m.headerImage) > 0 {
+		params.Attachments = []*resend.Attachment{
+			{
+				Content:   m.headerImage,
+				Filename:  "header.jpg",
+				ContentId: headerImageCID,
+			},
+		}
+	}
+
+	sent, err := m.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("sending email via resend: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Email sent", "provider", "resend", "to", to, "id", sent.Id)
+	return nil
+}
@@ -0,0 +1,339 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTP sends the digest through an SMTP relay, using STARTTLS or implicit TLS
+// and PLAIN or LOGIN authentication.
+type SMTP struct {
+	from        string
+	host        string
+	port        int
+	username    string
+	password    string
+	security    string
+	auth        string
+	headerImage []byte
+	tlsConfig   *tls.Config
+}
+
+func NewSMTP(from string, cfg config.SMTPConfig, headerImage []byte) (*SMTP, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("smtp: host is required")
+	}
+
+	security := cfg.TLS
+	if security == "" {
+		security = "starttls"
+	}
+	switch security {
+	case "starttls", "implicit", "none":
+	default:
+		return nil, fmt.Errorf("smtp: unknown tls mode %q (want starttls, implicit or none)", cfg.TLS)
+	}
+
+	switch cfg.Auth {
+	case "", "plain", "login":
+	default:
+		return nil, fmt.Errorf("smtp: unknown auth mechanism %q (want plain or login)", cfg.Auth)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+		if security == "implicit" {
+			port = 465
+		}
+	}
+
+	return &SMTP{
+		from:        from,
+		host:        cfg.Host,
+		port:        port,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		security:    security,
+		auth:        strings.ToLower(cfg.Auth),
+		headerImage: headerImage,
+		tlsConfig:   &tls.Config{ServerName: cfg.Host},
+	}, nil
+}
+
+func (m *SMTP) Send(ctx context.Context, to string, email *renderer.RenderedEmail) error {
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("parsing from address: %w", err)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("parsing to address: %w", err)
+	}
+
+	msg, err := buildMessage(from, rcpt, email.Subject, email, m.headerImage)
+	if err != nil {
+		return fmt.Errorf("building message: %w", err)
+	}
+
+	c, err := m.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to %s:%d: %w", m.host, m.port, err)
+	}
+	defer c.Close()
+
+	if m.security == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", m.host)
+		}
+		if err := c.StartTLS(m.tlsConfig); err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+
+	if m.username != "" {
+		auth, err := m.authenticator(c)
+		if err != nil {
+			return err
+		}
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(rcpt.Address); err != nil {
+		return fmt.Errorf("RCPT TO: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	if err := c.Quit(); err != nil {
+		return fmt.Errorf("QUIT: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Email sent", "provider", "smtp", "host", m.host, "to", rcpt.Address)
+	return nil
+}
+
+func (m *SMTP) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	var err error
+	if m.security == "implicit" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: m.tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(2 * smtpTimeout))
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// authenticator picks the configured mechanism, or the first of PLAIN and
+// LOGIN the server advertises.
+func (m *SMTP) authenticator(c *smtp.Client) (smtp.Auth, error) {
+	mech := m.auth
+	if mech == "" {
+		_, offered := c.Extension("AUTH")
+		for _, candidate := range []string{"PLAIN", "LOGIN"} {
+			if strings.Contains(" "+strings.ToUpper(offered)+" ", " "+candidate+" ") {
+				mech = strings.ToLower(candidate)
+				break
+			}
+		}
+		if mech == "" {
+			return nil, fmt.Errorf("smtp server %s offers no supported auth mechanism (have %q)", m.host, offered)
+		}
+	}
+
+	if mech == "login" {
+		return &loginAuth{username: m.username, password: m.password, host: m.host}, nil
+	}
+	return smtp.PlainAuth("", m.username, m.password, m.host), nil
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp lacks. Like
+// smtp.PlainAuth it refuses to send credentials over an unencrypted
+// connection to anything but localhost.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// buildMessage assembles the digest as multipart/alternative with a plain
+// text part and a multipart/related part holding the HTML and the inline
+// header image, which the HTML references as cid:header-image.
+func buildMessage(from, to *mail.Address, subject string, email *renderer.RenderedEmail, headerImage []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	alt := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID(from.Address))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", alt.Boundary())
+
+	if err := writeQuotedPrintable(alt, "text/plain; charset=utf-8", email.Text); err != nil {
+		return nil, err
+	}
+
+	if len(headerImage) == 0 {
+		if err := writeQuotedPrintable(alt, "text/html; charset=utf-8", email.HTML); err != nil {
+			return nil, err
+		}
+		if err := alt.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var relBuf bytes.Buffer
+	rel := multipart.NewWriter(&relBuf)
+	if err := writeQuotedPrintable(rel, "text/html; charset=utf-8", email.HTML); err != nil {
+		return nil, err
+	}
+
+	img, err := rel.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {http.DetectContentType(headerImage) + `; name="header.jpg"`},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-ID":                {"<" + headerImageCID + ">"},
+		"Content-Disposition":       {`inline; filename="header.jpg"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64Lines(img, headerImage); err != nil {
+		return nil, err
+	}
+	if err := rel.Close(); err != nil {
+		return nil, err
+	}
+
+	related, err := alt.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf(`multipart/related; boundary=%q; type="text/html"`, rel.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := related.Write(relBuf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := alt.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64Lines writes data base64-encoded in lines of 76 characters, as
+// RFC 2045 requires.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+func messageID(from string) string {
+	domain := "burrow.local"
+	if _, d, ok := strings.Cut(from, "@"); ok && d != "" {
+		domain = d
+	}
+	var b [12]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b[:]), domain)
+}
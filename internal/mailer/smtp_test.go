@@ -0,0 +1,328 @@
+package mailer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+)
+
+// fakeSMTP is a minimal in-process SMTP server that accepts one message.
+type fakeSMTP struct {
+	ln       net.Listener
+	tls      *tls.Config
+	authMech string
+	user     string
+	pass     string
+
+	done    chan struct{}
+	message []byte
+	from    string
+	rcpt    string
+	usedTLS bool
+	err     error
+}
+
+func startFakeSMTP(t *testing.T, tlsConfig *tls.Config, authMech string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{ln: ln, tls: tlsConfig, authMech: authMech, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTP) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		s.err = err
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...any) { tp.PrintfLine(format, args...) }
+	reply("220 localhost ESMTP fake")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			s.err = err
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			if s.tls != nil && !s.usedTLS {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH %s", s.authMech)
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				s.err = err
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			s.usedTLS = true
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			switch mech {
+			case "PLAIN":
+				raw, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(raw), "\x00")
+				if len(parts) == 3 {
+					s.user, s.pass = parts[1], parts[2]
+				}
+			case "LOGIN":
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				u, _ := tp.ReadLine()
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				p, _ := tp.ReadLine()
+				user, _ := base64.StdEncoding.DecodeString(u)
+				pass, _ := base64.StdEncoding.DecodeString(p)
+				s.user, s.pass = string(user), string(pass)
+			}
+			reply("235 authenticated")
+		case "MAIL":
+			s.from = arg
+			reply("250 ok")
+		case "RCPT":
+			s.rcpt = arg
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			s.message, s.err = tp.ReadDotBytes()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unsupported")
+		}
+	}
+}
+
+func (s *fakeSMTP) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fake SMTP server did not finish")
+	}
+	if s.err != nil {
+		t.Fatalf("fake SMTP server: %v", s.err)
+	}
+}
+
+func testEmail() *renderer.RenderedEmail {
+	return &renderer.RenderedEmail{
+		Subject: "Burrow Digest — Jan 2, 2026",
+		HTML:    `<html><body><img src="cid:header-image"><p>Grüße aus dem Bau</p></body></html>`,
+		Text:    "Grüße aus dem Bau",
+	}
+}
+
+func TestSMTPSendPlainAuth(t *testing.T) {
+	server := startFakeSMTP(t, nil, "PLAIN LOGIN")
+
+	m, err := NewSMTP("The Burrow <burrow@example.com>", config.SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "burrow",
+		Password: "hunter2",
+		TLS:      "none",
+	}, []byte("\xff\xd8\xff\xe0fake jpeg"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := m.Send(context.Background(), "reader@example.com", testEmail()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.wait(t)
+
+	if server.user != "burrow" || server.pass != "hunter2" {
+		t.Errorf("unexpected credentials %q/%q", server.user, server.pass)
+	}
+	if server.from != "FROM:<burrow@example.com>" {
+		t.Errorf("unexpected MAIL argument %q", server.from)
+	}
+	if server.rcpt != "TO:<reader@example.com>" {
+		t.Errorf("unexpected RCPT argument %q", server.rcpt)
+	}
+
+	checkDigestMIME(t, server.message)
+}
+
+func TestSMTPSendStartTLSLoginAuth(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := startFakeSMTP(t, serverTLS, "LOGIN")
+
+	m, err := NewSMTP("burrow@example.com", config.SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "burrow",
+		Password: "hunter2",
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.tlsConfig = clientTLS
+
+	if err := m.Send(context.Background(), "reader@example.com", testEmail()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.wait(t)
+
+	if !server.usedTLS {
+		t.Error("expected the connection to be upgraded with STARTTLS")
+	}
+	if server.user != "burrow" || server.pass != "hunter2" {
+		t.Errorf("unexpected credentials %q/%q", server.user, server.pass)
+	}
+}
+
+func TestNewSMTPDefaults(t *testing.T) {
+	m, err := NewSMTP("a@example.com", config.SMTPConfig{Host: "mail.example.com", TLS: "implicit"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.port != 465 {
+		t.Errorf("expected port 465 for implicit TLS, got %d", m.port)
+	}
+
+	if _, err := NewSMTP("a@example.com", config.SMTPConfig{Host: "mail.example.com", TLS: "ssl3"}, nil); err == nil {
+		t.Error("expected error for unknown tls mode")
+	}
+	if _, err := New(config.EmailConfig{Provider: "pigeon"}, nil); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+// checkDigestMIME verifies the multipart/alternative + multipart/related
+// structure with the inline header image.
+func checkDigestMIME(t *testing.T, raw []byte) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, _ := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if !strings.HasPrefix(subject, "Burrow Digest — ") {
+		t.Errorf("unexpected subject %q", subject)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative, got %q", mediaType)
+	}
+
+	alt := multipart.NewReader(msg.Body, params["boundary"])
+	text, err := alt.NextPart()
+	if err != nil {
+		t.Fatalf("reading text part: %v", err)
+	}
+	if ct := text.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text/plain first, got %q", ct)
+	}
+	body, _ := io.ReadAll(text)
+	if string(body) != "Grüße aus dem Bau" {
+		t.Errorf("unexpected text body %q", body)
+	}
+
+	related, err := alt.NextPart()
+	if err != nil {
+		t.Fatalf("reading related part: %v", err)
+	}
+	mediaType, params, _ = mime.ParseMediaType(related.Header.Get("Content-Type"))
+	if mediaType != "multipart/related" {
+		t.Fatalf("expected multipart/related, got %q", mediaType)
+	}
+
+	rel := multipart.NewReader(related, params["boundary"])
+	html, err := rel.NextPart()
+	if err != nil {
+		t.Fatalf("reading html part: %v", err)
+	}
+	body, _ = io.ReadAll(html)
+	if !strings.Contains(string(body), `cid:header-image`) {
+		t.Errorf("expected HTML to reference the header image, got %q", body)
+	}
+
+	img, err := rel.NextPart()
+	if err != nil {
+		t.Fatalf("reading image part: %v", err)
+	}
+	if cid := img.Header.Get("Content-ID"); cid != "<header-image>" {
+		t.Errorf("expected Content-ID <header-image>, got %q", cid)
+	}
+	if ct := img.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/jpeg") {
+		t.Errorf("expected image/jpeg, got %q", ct)
+	}
+}
+
+// testTLSConfigs returns a server config with a self-signed certificate for
+// 127.0.0.1 and a client config that trusts it.
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "burrow test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{ServerName: "127.0.0.1", RootCAs: pool}
+	return server, client
+}
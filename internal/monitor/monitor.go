@@ -0,0 +1,262 @@
+// Package monitor serves the health and Prometheus metrics endpoints of the
+// scheduled daemon.
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+// schedulerTimeout bounds how long the scheduler may take to report its next
+// run before it counts as stuck.
+const schedulerTimeout = 2 * time.Second
+
+var (
+	fetchBuckets  = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	renderBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+)
+
+// NextRunFunc returns when the next digest is due. It fails if the scheduler
+// does not answer before ctx is done.
+type NextRunFunc func(ctx context.Context) (time.Time, error)
+
+// sourceMetrics are the metrics of one source.
+type sourceMetrics struct {
+	typ      string
+	duration *histogram
+	errors   int
+	items    int
+}
+
+// Monitor collects metrics from finished runs.
+type Monitor struct {
+	next NextRunFunc
+
+	mu      sync.Mutex
+	last    *state.Run
+	runs    map[bool]int
+	emails  map[bool]int
+	sources map[string]*sourceMetrics
+	render  *histogram
+}
+
+// New returns a monitor that asks next for the scheduler's next run. last is
+// the most recent run from the run log, or nil.
+func New(next NextRunFunc, last *state.Run) *Monitor {
+	return &Monitor{
+		next:    next,
+		last:    last,
+		runs:    make(map[bool]int),
+		emails:  make(map[bool]int),
+		sources: make(map[string]*sourceMetrics),
+		render:  newHistogram(renderBuckets),
+	}
+}
+
+// ObserveRun records a finished run.
+func (m *Monitor) ObserveRun(run state.Run) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.last = &run
+	m.runs[run.Sent]++
+	m.emails[false] += len(run.FailedRecipients)
+	m.emails[true] += len(run.Recipients) - len(run.FailedRecipients)
+	if run.RenderTime > 0 {
+		m.render.observe(run.RenderTime.Seconds())
+	}
+
+	for id, st := range run.Sources {
+		sm := m.sources[id]
+		if sm == nil {
+			sm = &sourceMetrics{duration: newHistogram(fetchBuckets)}
+			m.sources[id] = sm
+		}
+		sm.typ = st.Type
+		sm.duration.observe(st.Duration.Seconds())
+		sm.items = st.Items
+		if _, failed := run.FailedSources[id]; failed {
+			sm.errors++
+		}
+	}
+}
+
+// Handler serves /healthz and /metrics.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", m.serveHealth)
+	mux.HandleFunc("GET /metrics", m.serveMetrics)
+	return mux
+}
+
+type healthReport struct {
+	Status  string     `json:"status"`
+	Error   string     `json:"error,omitempty"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+	LastRun *lastRun   `json:"last_run,omitempty"`
+}
+
+type lastRun struct {
+	Finished time.Time `json:"finished"`
+	Edition  int       `json:"edition"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// serveHealth reports whether the scheduler is alive, together with the last
+// and next run. A failed last run does not make the daemon unhealthy.
+func (m *Monitor) serveHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), schedulerTimeout)
+	defer cancel()
+
+	report := healthReport{Status: "ok"}
+	code := http.StatusOK
+	if next, err := m.next(ctx); err != nil {
+		report.Status = "unhealthy"
+		report.Error = err.Error()
+		code = http.StatusServiceUnavailable
+	} else if !next.IsZero() {
+		report.NextRun = &next
+	}
+
+	m.mu.Lock()
+	if m.last != nil {
+		report.LastRun = &lastRun{
+			Finished: m.last.Finished,
+			Edition:  m.last.Edition,
+			Success:  m.last.Sent,
+			Error:    m.last.Error,
+		}
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+func (m *Monitor) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), schedulerTimeout)
+	defer cancel()
+	next, err := m.next(ctx)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	gauge(w, "burrow_scheduler_up", "Whether the scheduler answered.", boolValue(err == nil))
+	if err == nil && !next.IsZero() {
+		gauge(w, "burrow_next_run_timestamp_seconds", "When the next digest is due.", unix(next))
+	}
+	if m.last != nil {
+		gauge(w, "burrow_last_run_timestamp_seconds", "When the last run finished.", unix(m.last.Finished))
+		gauge(w, "burrow_last_run_success", "Whether the last run delivered the digest.", boolValue(m.last.Sent))
+	}
+
+	header(w, "burrow_runs_total", "counter", "Digest runs by outcome.")
+	fmt.Fprintf(w, "burrow_runs_total{result=\"success\"} %d\n", m.runs[true])
+	fmt.Fprintf(w, "burrow_runs_total{result=\"failure\"} %d\n", m.runs[false])
+
+	header(w, "burrow_emails_total", "counter", "Digest emails by outcome.")
+	fmt.Fprintf(w, "burrow_emails_total{result=\"sent\"} %d\n", m.emails[true])
+	fmt.Fprintf(w, "burrow_emails_total{result=\"failed\"} %d\n", m.emails[false])
+
+	header(w, "burrow_render_duration_seconds", "histogram", "Time spent rendering the digests of a run.")
+	m.render.write(w, "burrow_render_duration_seconds", "")
+
+	ids := make([]string, 0, len(m.sources))
+	for id := range m.sources {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	labels := func(id string) string {
+		return fmt.Sprintf(`source="%s",type="%s"`, labelEscaper.Replace(id), labelEscaper.Replace(m.sources[id].typ))
+	}
+
+	header(w, "burrow_fetch_duration_seconds", "histogram", "Time a source's fetch took, retries included.")
+	for _, id := range ids {
+		m.sources[id].duration.write(w, "burrow_fetch_duration_seconds", labels(id))
+	}
+	header(w, "burrow_fetch_errors_total", "counter", "Runs in which a source's fetch failed.")
+	for _, id := range ids {
+		fmt.Fprintf(w, "burrow_fetch_errors_total{%s} %d\n", labels(id), m.sources[id].errors)
+	}
+	header(w, "burrow_fetch_items", "gauge", "Items a source returned in the last run.")
+	for _, id := range ids {
+		fmt.Fprintf(w, "burrow_fetch_items{%s} %d\n", labels(id), m.sources[id].items)
+	}
+}
+
+// labelEscaper escapes a label value as the exposition format asks: only
+// backslashes, double quotes and newlines.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func gauge(w io.Writer, name, help string, v float64) {
+	header(w, name, "gauge", help)
+	fmt.Fprintf(w, "%s %g\n", name, v)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func unix(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// histogram is a cumulative Prometheus histogram.
+type histogram struct {
+	bounds []float64
+	counts []int
+	count  int
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// write prints the histogram's series with the given labels, which may be
+// empty.
+func (h *histogram) write(w io.Writer, name, labels string) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%g\"} %d\n", name, labels, sep, b, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janiskrasemann/burrow/internal/state"
+)
+
+var nextRunAt = time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)
+
+func scheduled(ctx context.Context) (time.Time, error) { return nextRunAt, nil }
+
+func stuck(ctx context.Context) (time.Time, error) {
+	<-ctx.Done()
+	return time.Time{}, fmt.Errorf("scheduler did not respond")
+}
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestHealthz(t *testing.T) {
+	m := New(scheduled, &state.Run{Finished: nextRunAt.Add(-24 * time.Hour), Edition: 41, Sent: true})
+	m.ObserveRun(state.Run{Finished: nextRunAt.Add(-time.Hour), Edition: 42, Error: "no recipients"})
+
+	code, body := get(t, m.Handler(), "/healthz")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	var report healthReport
+	if err := json.Unmarshal([]byte(body), &report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Status != "ok" || !report.NextRun.Equal(nextRunAt) {
+		t.Errorf("unexpected report %s", body)
+	}
+	if report.LastRun == nil || report.LastRun.Edition != 42 || report.LastRun.Success || report.LastRun.Error != "no recipients" {
+		t.Errorf("expected the failed last run, got %s", body)
+	}
+}
+
+func TestHealthzStuckScheduler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	New(stuck, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil).WithContext(ctx))
+
+	code, body := rec.Code, rec.Body.String()
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, "unhealthy") {
+		t.Errorf("expected 503, got %d %s", code, body)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := New(scheduled, nil)
+	m.ObserveRun(state.Run{
+		Sent:             true,
+		Recipients:       []string{"a@example.com", "b@example.com"},
+		FailedRecipients: map[string]string{"b@example.com": "mailbox full"},
+		FailedSources:    map[string]string{"readwise": "HTTP 502"},
+		RenderTime:       30 * time.Millisecond,
+		Sources: map[string]state.SourceStats{
+			"hackernews":        {Type: "hackernews", Duration: 800 * time.Millisecond, Items: 5},
+			"readwise":          {Type: "readwise", Duration: 2 * time.Second},
+			"über\t\"blick\"\n": {Type: "feed", Items: 1},
+		},
+	})
+
+	_, body := get(t, m.Handler(), "/metrics")
+
+	for _, want := range []string{
+		"burrow_scheduler_up 1\n",
+		"burrow_next_run_timestamp_seconds 1.7724348e+09\n",
+		`burrow_runs_total{result="success"} 1`,
+		`burrow_emails_total{result="sent"} 1`,
+		`burrow_emails_total{result="failed"} 1`,
+		`burrow_render_duration_seconds_bucket{le="0.025"} 0`,
+		`burrow_render_duration_seconds_bucket{le="0.05"} 1`,
+		`burrow_fetch_duration_seconds_bucket{source="hackernews",type="hackernews",le="1"} 1`,
+		`burrow_fetch_duration_seconds_count{source="readwise",type="readwise"} 1`,
+		`burrow_fetch_errors_total{source="readwise",type="readwise"} 1`,
+		`burrow_fetch_errors_total{source="hackernews",type="hackernews"} 0`,
+		`burrow_fetch_items{source="hackernews",type="hackernews"} 5`,
+		"burrow_fetch_items{source=\"über\t\\\"blick\\\"\\n\",type=\"feed\"} 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Package preview serves a rendered digest over HTTP for template work. The
+// results are fetched once; the templates are reloaded on every request.
+package preview
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+)
+
+// LoadFunc builds a renderer from the current templates.
+type LoadFunc func() (*renderer.Renderer, error)
+
+type Server struct {
+	digest      renderer.Digest
+	load        LoadFunc
+	headerImage []byte
+}
+
+func New(d renderer.Digest, load LoadFunc, headerImage []byte) *Server {
+	return &Server{digest: d, load: load, headerImage: headerImage}
+}
+
+// Handler serves the HTML digest at /, the text digest at /text and the
+// header image at /header.jpg. The digest pages accept ?fail=<id> and
+// ?empty=<id> (repeatable or comma-separated) to simulate a failed or empty
+// section.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		email, ok := s.render(w, r)
+		if !ok {
+			return
+		}
+		html := strings.ReplaceAll(email.HTML, "cid:header-image", "/header.jpg")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(html))
+	})
+	mux.HandleFunc("GET /text", func(w http.ResponseWriter, r *http.Request) {
+		email, ok := s.render(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(email.Text))
+	})
+	mux.HandleFunc("GET /header.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", http.DetectContentType(s.headerImage))
+		w.Write(s.headerImage)
+	})
+	return mux
+}
+
+func (s *Server) render(w http.ResponseWriter, r *http.Request) (*renderer.RenderedEmail, bool) {
+	rend, err := s.load()
+	if err != nil {
+		http.Error(w, "loading templates: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	d := s.digest
+	d.Results = Simulate(d.Results, ids(r, "fail"), ids(r, "empty"))
+	email, err := rend.RenderDigest(d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return email, true
+}
+
+// Simulate returns a copy of results with the sources in fail marked as
+// failed and the data of the sources in empty replaced by its zero value, so
+// type assertions in the templates still match.
+func Simulate(results []fetcher.Result, fail, empty []string) []fetcher.Result {
+	out := make([]fetcher.Result, len(results))
+	for i, res := range results {
+		if contains(empty, res) && res.Data != nil {
+			res.Data = reflect.Zero(reflect.TypeOf(res.Data)).Interface()
+		}
+		if contains(fail, res) {
+			res.Data = nil
+			res.Error = errSimulated
+		}
+		out[i] = res
+	}
+	return out
+}
+
+var errSimulated = errors.New("simulated failure (preview)")
+
+// contains reports whether ids names the result by source ID or type; "all"
+// matches every result.
+func contains(ids []string, res fetcher.Result) bool {
+	for _, id := range ids {
+		if id == "all" || id == res.ID || id == res.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func ids(r *http.Request, key string) []string {
+	var out []string
+	for _, v := range r.URL.Query()[key] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package preview
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/renderer"
+)
+
+func testResults() []fetcher.Result {
+	return []fetcher.Result{
+		{Type: fetcher.TypeHackerNews, ID: "hackernews", Title: "Hacker News", Data: []fetcher.HNPost{{Title: "Post"}}},
+		{Type: fetcher.TypeReddit, ID: "reddit", Title: "Reddit", Data: []fetcher.RedditPost{{Title: "Thread"}}},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	results := testResults()
+	out := Simulate(results, []string{"reddit"}, []string{fetcher.TypeHackerNews})
+
+	posts, ok := out[0].Data.([]fetcher.HNPost)
+	if !ok || len(posts) != 0 {
+		t.Errorf("expected empty []HNPost, got %#v", out[0].Data)
+	}
+	if out[1].Error == nil || out[1].Data != nil {
+		t.Errorf("expected reddit to fail, got %+v", out[1])
+	}
+	if results[1].Error != nil || len(results[0].Data.([]fetcher.HNPost)) != 1 {
+		t.Error("expected the cached results to be left alone")
+	}
+}
+
+func TestHandlerReloadsTemplates(t *testing.T) {
+	tpl := `{{range .Results}}{{.ID}}:{{if .Error}}failed{{else}}ok{{end}} {{end}}<img src="cid:header-image">`
+	loads := 0
+	load := func() (*renderer.Renderer, error) {
+		loads++
+		return renderer.New(tpl, tpl)
+	}
+	srv := httptest.NewServer(New(renderer.Digest{Edition: 7, Results: testResults()}, load, []byte("\xff\xd8\xff\xe0")).Handler())
+	defer srv.Close()
+
+	body := get(t, srv.URL+"/?fail=reddit")
+	if !strings.Contains(body, "hackernews:ok reddit:failed") {
+		t.Errorf("unexpected body %q", body)
+	}
+	if !strings.Contains(body, `src="/header.jpg"`) {
+		t.Errorf("expected the header image to be served locally, got %q", body)
+	}
+
+	tpl = `text edition {{.Edition}}`
+	if body := get(t, srv.URL+"/text"); body != "text edition 7" {
+		t.Errorf("expected reloaded template, got %q", body)
+	}
+	if loads != 2 {
+		t.Errorf("expected templates to load per request, got %d loads", loads)
+	}
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+	return string(body)
+}
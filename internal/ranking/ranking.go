@@ -0,0 +1,107 @@
+// Package ranking scores items by their popularity on the source combined
+// with the reader's interest profile.
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+type term struct {
+	word   string
+	re     *regexp.Regexp
+	weight float64
+}
+
+type boost struct {
+	domain string
+	weight float64
+}
+
+// Profile implements fetcher.Ranker. An item scores log2(2 + popularity),
+// doubled for every point of weight its matching terms and domain add up to.
+// The logarithm keeps a viral post from outranking everything the reader
+// actually cares about; the 2 keeps a post without points at a score of 1, so
+// its weights still count.
+type Profile struct {
+	terms   []term
+	boosted []boost
+	muted   []string
+}
+
+// New builds a profile from the config. It returns nil if the profile is
+// empty, so callers can skip ranking altogether.
+func New(cfg config.InterestConfig) *Profile {
+	if len(cfg.Terms)+len(cfg.BoostDomains)+len(cfg.MuteDomains) == 0 {
+		return nil
+	}
+
+	p := &Profile{}
+	for w, weight := range cfg.Terms {
+		p.terms = append(p.terms, term{word: w, re: fetcher.WordPattern(w), weight: weight})
+	}
+	for d, weight := range cfg.BoostDomains {
+		p.boosted = append(p.boosted, boost{domain: normalizeDomain(d), weight: weight})
+	}
+	// Map order is random; keep explanations stable.
+	sort.Slice(p.terms, func(i, j int) bool { return p.terms[i].word < p.terms[j].word })
+	sort.Slice(p.boosted, func(i, j int) bool { return p.boosted[i].domain < p.boosted[j].domain })
+
+	for _, d := range cfg.MuteDomains {
+		p.muted = append(p.muted, normalizeDomain(d))
+	}
+	return p
+}
+
+func (p *Profile) Rank(item fetcher.Item, popularity int) fetcher.Ranking {
+	info := item.Info()
+	base := math.Log2(2 + float64(max(popularity, 0)))
+	baseText := fmt.Sprintf("%.1f (%d pts)", base, popularity)
+
+	domain := info.Domain()
+	for _, d := range p.muted {
+		if matchesDomain(domain, d) {
+			return fetcher.Ranking{Score: 0, Explanation: baseText + " × 0 (muted " + d + ") = 0"}
+		}
+	}
+
+	var total float64
+	var reasons []string
+	text := info.Title + "\n" + info.Text
+	for _, t := range p.terms {
+		if t.re.MatchString(text) {
+			total += t.weight
+			reasons = append(reasons, fmt.Sprintf("%+g %s", t.weight, t.word))
+		}
+	}
+	for _, b := range p.boosted {
+		if matchesDomain(domain, b.domain) {
+			total += b.weight
+			reasons = append(reasons, fmt.Sprintf("%+g %s", b.weight, b.domain))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return fetcher.Ranking{Score: base, Explanation: baseText}
+	}
+	score := base * math.Exp2(total)
+	return fetcher.Ranking{
+		Score:       score,
+		Explanation: fmt.Sprintf("%s × 2^(%s) = %.1f", baseText, strings.Join(reasons, ", "), score),
+	}
+}
+
+func normalizeDomain(d string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "www.")
+}
+
+// matchesDomain reports whether domain is d or one of its subdomains.
+func matchesDomain(domain, d string) bool {
+	return domain != "" && (domain == d || strings.HasSuffix(domain, "."+d))
+}
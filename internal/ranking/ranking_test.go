@@ -0,0 +1,46 @@
+package ranking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/janiskrasemann/burrow/internal/config"
+	"github.com/janiskrasemann/burrow/internal/fetcher"
+)
+
+func TestRank(t *testing.T) {
+	p := New(config.InterestConfig{
+		Terms:        map[string]float64{"rust": 2, "crypto": -1},
+		BoostDomains: map[string]float64{"lwn.net": 1},
+		MuteDomains:  []string{"medium.com"},
+	})
+
+	plain := p.Rank(fetcher.HNPost{Title: "A new phone", URL: "https://a.org"}, 1022)
+	if plain.Score != 10 || plain.Explanation != "10.0 (1022 pts)" {
+		t.Errorf("unexpected ranking %+v", plain)
+	}
+
+	boosted := p.Rank(fetcher.HNPost{Title: "Rust in the kernel", URL: "https://lwn.net/a"}, 6)
+	if boosted.Score != 24 {
+		t.Errorf("expected 3 × 2^3 = 24, got %v", boosted.Score)
+	}
+	if boosted.Explanation != "3.0 (6 pts) × 2^(+2 rust, +1 lwn.net) = 24.0" {
+		t.Errorf("unexpected explanation %q", boosted.Explanation)
+	}
+
+	fresh := p.Rank(fetcher.HNPost{Title: "Rust 2.0 released", URL: "https://a.org"}, 0)
+	if fresh.Score != 4 {
+		t.Errorf("expected a post without points to still count its weights, 1 × 2^2 = 4, got %v", fresh.Score)
+	}
+
+	muted := p.Rank(fetcher.HNPost{Title: "Rust tips", URL: "https://blog.medium.com/x"}, 5000)
+	if muted.Score != 0 || !strings.Contains(muted.Explanation, "muted medium.com") {
+		t.Errorf("unexpected ranking %+v", muted)
+	}
+}
+
+func TestEmptyProfile(t *testing.T) {
+	if New(config.InterestConfig{}) != nil {
+		t.Error("expected nil profile without any interests")
+	}
+}
@@ -9,19 +9,37 @@ import (
 	"time"
 
 	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/internal/filter"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/renderer/html"
 )
 
-type DigestData struct {
-	Date    string
+// Digest is the content of one email.
+type Digest struct {
 	Edition int
 	Results []fetcher.Result
+	// Filtered lists the candidates filter rules hid from Results. Fetchers
+	// screen every candidate they consider, so most of these would not have
+	// been selected anyway.
+	Filtered []filter.Hidden
+	// ShowFiltered lists the hidden items in the footer rather than only
+	// counting them.
+	ShowFiltered bool
+	// Explain shows how each ranked item was scored, for the preview.
+	Explain bool
+	// AdminNotes are alerts about failing sources, shown in a box at the top.
+	AdminNotes []string
+}
+
+type DigestData struct {
+	Date string
+	Digest
 }
 
 type RenderedEmail struct {
-	HTML string
-	Text string
+	Subject string
+	HTML    string
+	Text    string
 }
 
 type Renderer struct {
@@ -40,39 +58,59 @@ func New(htmlTemplate, textTemplate string) (*Renderer, error) {
 	isEven := func(n int) bool { return n%2 == 0 }
 
 	funcMap := htmltpl.FuncMap{
-		"weatherIcon": weatherIcon,
-		"hasPrefix":   strings.HasPrefix,
-		"hnPosts":     asHNPosts,
-		"weatherData": asWeatherData,
-		"highlights":  asHighlights,
+		"weatherIcon":   weatherIcon,
+		"hasPrefix":     strings.HasPrefix,
+		"hnPosts":       asHNPosts,
+		"weatherData":   asWeatherData,
+		"umbrellaLine":  umbrellaLine,
+		"amount":        amount,
+		"levelColor":    levelColor,
+		"daylight":      daylight,
+		"dayChange":     dayChange,
+		"moonIcon":      moonIcon,
+		"dayLabel":      dayLabel,
+		"highlights":    asHighlights,
 		"redditPosts":   asRedditPosts,
 		"redditLead":    redditLead,
 		"redditSidebar": redditSidebar,
 		"markdown":      renderMarkdown,
-		"excerpt":     excerpt,
-		"slice":       sliceFrom,
+		"excerpt":       excerpt,
+		"slice":         sliceFrom,
 		"nextSection":   nextSection,
 		"isEven":        isEven,
 		"nitterPosts":   asNitterPosts,
 		"nitterTimeAgo": nitterTimeAgo,
+		"cachedLabel":   cachedLabel,
 		"unsplashImage": asUnsplashImage,
+		"ofType":        ofType,
+		"feedItems":     asFeedItems,
 	}
 	textFuncMap := texttpl.FuncMap{
-		"weatherIcon": weatherIcon,
-		"hasPrefix":   strings.HasPrefix,
-		"hnPosts":     asHNPosts,
-		"weatherData": asWeatherData,
-		"highlights":  asHighlights,
+		"weatherIcon":   weatherIcon,
+		"hasPrefix":     strings.HasPrefix,
+		"hnPosts":       asHNPosts,
+		"weatherData":   asWeatherData,
+		"umbrellaLine":  umbrellaLine,
+		"amount":        amount,
+		"levelColor":    levelColor,
+		"daylight":      daylight,
+		"dayChange":     dayChange,
+		"moonIcon":      moonIcon,
+		"dayLabel":      dayLabel,
+		"highlights":    asHighlights,
 		"redditPosts":   asRedditPosts,
 		"redditLead":    redditLead,
 		"redditSidebar": redditSidebar,
 		"excerpt":       excerpt,
-		"slice":       sliceFrom,
+		"slice":         sliceFrom,
 		"nextSection":   func() int { return 0 },
 		"isEven":        isEven,
 		"nitterPosts":   asNitterPosts,
 		"nitterTimeAgo": nitterTimeAgo,
+		"cachedLabel":   cachedLabel,
 		"unsplashImage": asUnsplashImage,
+		"ofType":        ofType,
+		"feedItems":     asFeedItems,
 	}
 
 	ht, err := htmltpl.New("digest.html").Funcs(funcMap).Parse(htmlTemplate)
@@ -88,13 +126,18 @@ func New(htmlTemplate, textTemplate string) (*Renderer, error) {
 	return &Renderer{htmlTpl: ht, textTpl: tt, sectionCounter: counter}, nil
 }
 
+// Render renders a digest of just the given results.
 func (r *Renderer) Render(results []fetcher.Result, edition int) (*RenderedEmail, error) {
+	return r.RenderDigest(Digest{Edition: edition, Results: results})
+}
+
+func (r *Renderer) RenderDigest(d Digest) (*RenderedEmail, error) {
 	*r.sectionCounter = 0
 
+	now := time.Now()
 	data := DigestData{
-		Date:    time.Now().Format("Monday, January 2, 2006"),
-		Edition: edition,
-		Results: results,
+		Date:   now.Format("Monday, January 2, 2006"),
+		Digest: d,
 	}
 
 	var htmlBuf bytes.Buffer
@@ -108,11 +151,23 @@ func (r *Renderer) Render(results []fetcher.Result, edition int) (*RenderedEmail
 	}
 
 	return &RenderedEmail{
-		HTML: htmlBuf.String(),
-		Text: textBuf.String(),
+		Subject: fmt.Sprintf("Burrow Digest — %s", now.Format("Jan 2, 2006")),
+		HTML:    htmlBuf.String(),
+		Text:    textBuf.String(),
 	}, nil
 }
 
+// ofType returns the results of all sources of the given type, in config order.
+func ofType(typ string, results []fetcher.Result) []fetcher.Result {
+	var matched []fetcher.Result
+	for _, r := range results {
+		if r.Type == typ {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
 func asHNPosts(data any) []fetcher.HNPost {
 	if posts, ok := data.([]fetcher.HNPost); ok {
 		return posts
@@ -120,13 +175,101 @@ func asHNPosts(data any) []fetcher.HNPost {
 	return nil
 }
 
-func asWeatherData(data any) *fetcher.WeatherData {
-	if w, ok := data.(fetcher.WeatherData); ok {
-		return &w
+func asWeatherData(data any) []fetcher.WeatherData {
+	switch w := data.(type) {
+	case []fetcher.WeatherData:
+		return w
+	case fetcher.WeatherData:
+		return []fetcher.WeatherData{w}
 	}
 	return nil
 }
 
+// amount formats a precipitation amount in its unit, with the precision the
+// unit calls for.
+func amount(v float64, unit string) string {
+	if unit == "in" {
+		return fmt.Sprintf("%.2f in", v)
+	}
+	return fmt.Sprintf("%.1f %s", v, unit)
+}
+
+// levelColor returns the colour air quality, UV and pollen readings of a level
+// are shown in.
+func levelColor(l fetcher.Level) string {
+	switch l {
+	case fetcher.LevelLow:
+		return "#2e7d32"
+	case fetcher.LevelModerate:
+		return "#b08800"
+	case fetcher.LevelHigh:
+		return "#e65100"
+	case fetcher.LevelVeryHigh:
+		return "#c62828"
+	default:
+		return "#6a1b9a"
+	}
+}
+
+// daylight formats a day length like "10h 36m".
+func daylight(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// dayChange says how much longer today is than yesterday, like "+2m 51s" or
+// "−1m 05s", or returns "" if yesterday's day length is unknown.
+func dayChange(s *fetcher.Sky) string {
+	if s == nil || s.YesterdayDaylight == 0 || s.Daylight == 0 {
+		return ""
+	}
+	d := s.Daylight - s.YesterdayDaylight
+	sign := "+"
+	if d < 0 {
+		sign, d = "−", -d
+	}
+	if d < time.Minute {
+		return fmt.Sprintf("%s%ds", sign, int(d.Seconds()))
+	}
+	return fmt.Sprintf("%s%dm %02ds", sign, int(d.Minutes()), int(d.Seconds())%60)
+}
+
+var moonIcons = []string{"🌑", "🌒", "🌓", "🌔", "🌕", "🌖", "🌗", "🌘"}
+
+func moonIcon(p fetcher.MoonPhase) string {
+	if p.Phase < 0 || p.Phase >= len(moonIcons) {
+		return ""
+	}
+	return moonIcons[p.Phase]
+}
+
+// umbrellaLine says whether the commute needs an umbrella, or returns "" if
+// the forecast does not cover the commute.
+func umbrellaLine(w *fetcher.WeatherData) string {
+	u := w.Umbrella
+	switch {
+	case u == nil:
+		return ""
+	case u.Needed:
+		return fmt.Sprintf("Take an umbrella: %.0f%% chance of rain around %s.", u.Chance, u.At.Format("15:04"))
+	default:
+		return fmt.Sprintf("No umbrella needed on the commute (at most %.0f%% chance of rain).", u.Chance)
+	}
+}
+
+// dayLabel names a day of the outlook: "Tomorrow", or its weekday and date.
+func dayLabel(date time.Time) string {
+	return dayLabelAt(date, time.Now())
+}
+
+func dayLabelAt(date, now time.Time) string {
+	y, m, d := now.In(date.Location()).AddDate(0, 0, 1).Date()
+	if dy, dm, dd := date.Date(); dy == y && dm == m && dd == d {
+		return "Tomorrow"
+	}
+	return date.Format("Mon 2")
+}
+
 func asHighlights(data any) []fetcher.Highlight {
 	if h, ok := data.([]fetcher.Highlight); ok {
 		return h
@@ -245,6 +388,13 @@ func asNitterPosts(data any) []fetcher.NitterPost {
 	return nil
 }
 
+func asFeedItems(data any) []fetcher.FeedItem {
+	if items, ok := data.([]fetcher.FeedItem); ok {
+		return items
+	}
+	return nil
+}
+
 func asUnsplashImage(data any) *fetcher.UnsplashImage {
 	if img, ok := data.(*fetcher.UnsplashImage); ok {
 		return img
@@ -266,6 +416,28 @@ func nitterTimeAgo(t time.Time) string {
 	}
 }
 
+// cachedLabel says when a cached section standing in for a failed fetch was
+// fetched, e.g. "from yesterday".
+func cachedLabel(at time.Time) string {
+	return cachedLabelAt(at, time.Now())
+}
+
+func cachedLabelAt(at, now time.Time) string {
+	at = at.In(now.Location())
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch {
+	case !at.Before(today):
+		return "from earlier today"
+	case !at.Before(today.AddDate(0, 0, -1)):
+		return "from yesterday"
+	case !at.Before(today.AddDate(0, 0, -6)):
+		return "from " + at.Format("Monday")
+	default:
+		return "from " + at.Format("Jan 2")
+	}
+}
+
 func weatherIcon(code int) string {
 	switch {
 	case code == 0:
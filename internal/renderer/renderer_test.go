@@ -4,13 +4,15 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/janiskrasemann/burrow/internal/fetcher"
+	"github.com/janiskrasemann/burrow/templates"
 )
 
 func TestRenderHTML(t *testing.T) {
-	htmlTpl := `<html><body>{{.Date}}{{range .Results}}{{if .Error}}ERROR{{else}}{{if eq .Name "Hacker News"}}{{range hnPosts .Data}}<p>{{.Title}}</p>{{end}}{{end}}{{end}}{{end}}</body></html>`
-	textTpl := `{{.Date}}{{range .Results}}{{.Name}}{{end}}`
+	htmlTpl := `<html><body>{{.Date}}{{range .Results}}{{if .Error}}ERROR{{else}}{{if eq .Type "hackernews"}}{{range hnPosts .Data}}<p>{{.Title}}</p>{{end}}{{end}}{{end}}{{end}}</body></html>`
+	textTpl := `{{.Date}}{{range .Results}}{{.Title}}{{end}}`
 
 	r, err := New(htmlTpl, textTpl)
 	if err != nil {
@@ -19,7 +21,9 @@ func TestRenderHTML(t *testing.T) {
 
 	results := []fetcher.Result{
 		{
-			Name: "Hacker News",
+			Type:  fetcher.TypeHackerNews,
+			ID:    "hackernews",
+			Title: "Hacker News",
 			Data: []fetcher.HNPost{
 				{Title: "Test Post", Points: 100, NumComments: 50, ObjectID: "1", URL: "https://example.com"},
 			},
@@ -40,8 +44,8 @@ func TestRenderHTML(t *testing.T) {
 }
 
 func TestRenderErrorModule(t *testing.T) {
-	htmlTpl := `{{range .Results}}{{if .Error}}ERROR:{{.Name}}{{end}}{{end}}`
-	textTpl := `{{range .Results}}{{if .Error}}ERROR:{{.Name}}{{end}}{{end}}`
+	htmlTpl := `{{range .Results}}{{if .Error}}ERROR:{{.Title}}{{end}}{{end}}`
+	textTpl := `{{range .Results}}{{if .Error}}ERROR:{{.Title}}{{end}}{{end}}`
 
 	r, err := New(htmlTpl, textTpl)
 	if err != nil {
@@ -49,7 +53,7 @@ func TestRenderErrorModule(t *testing.T) {
 	}
 
 	results := []fetcher.Result{
-		{Name: "Weather", Error: fmt.Errorf("network error")},
+		{Type: fetcher.TypeWeather, ID: "weather", Title: "Weather", Error: fmt.Errorf("network error")},
 	}
 
 	email, err := r.Render(results, 1)
@@ -61,3 +65,341 @@ func TestRenderErrorModule(t *testing.T) {
 		t.Error("expected HTML to show error for Weather module")
 	}
 }
+
+func TestRenderSectionsByType(t *testing.T) {
+	htmlTpl := `{{range ofType "reddit" .Results}}[{{.Title}}:{{range redditPosts .Data}}{{.Title}}{{end}}]{{end}}`
+	textTpl := `{{range .Results}}{{.ID}} {{end}}`
+
+	r, err := New(htmlTpl, textTpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := []fetcher.Result{
+		{Type: fetcher.TypeReddit, ID: "reddit-tech", Title: "Tech", Data: []fetcher.RedditPost{{Title: "Go 2"}}},
+		{Type: fetcher.TypeHackerNews, ID: "hackernews", Title: "Reddit"},
+		{Type: fetcher.TypeReddit, ID: "reddit-de", Title: "Germany", Data: []fetcher.RedditPost{{Title: "Bahn"}}},
+	}
+
+	email, err := r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if email.HTML != "[Tech:Go 2][Germany:Bahn]" {
+		t.Errorf("unexpected HTML: %q", email.HTML)
+	}
+}
+
+func TestRenderFeedExcerpt(t *testing.T) {
+	htmlTpl, _ := templates.Read("", templates.HTML)
+	textTpl, _ := templates.Read("", templates.Text)
+	r, err := New(htmlTpl, textTpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	published := time.Date(2026, 3, 2, 6, 30, 0, 0, time.UTC)
+	results := []fetcher.Result{{
+		Type:  fetcher.TypeFeed,
+		ID:    "feed",
+		Title: "Feeds",
+		Data: []fetcher.FeedItem{
+			{Title: "Release notes", Link: "https://example.com/a", Source: "Blog", Published: published,
+				Summary: "Version 2 is out. It is faster. And smaller."},
+			{Title: "No summary", Link: "https://example.com/b", Published: published},
+		},
+	}}
+
+	email, err := r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"  * Release notes\n    Version 2 is out. It is faster.\n    Blog | Mar 2, 06:30\n",
+		"  * No summary\n    Mar 2, 06:30\n",
+	} {
+		if !strings.Contains(email.Text, want) {
+			t.Errorf("expected %q in the text:\n%s", want, email.Text)
+		}
+	}
+}
+
+func TestCachedLabel(t *testing.T) {
+	now := time.Date(2026, 3, 5, 7, 0, 0, 0, time.UTC)
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{now.Add(-2 * time.Hour), "from earlier today"},
+		{now.Add(-24 * time.Hour), "from yesterday"},
+		{now.Add(-31 * time.Hour), "from yesterday"},
+		{now.Add(-72 * time.Hour), "from Monday"},
+		{now.AddDate(0, 0, -10), "from Feb 23"},
+	}
+	for _, c := range cases {
+		if got := cachedLabelAt(c.at, now); got != c.want {
+			t.Errorf("cachedLabelAt(%s) = %q, want %q", c.at, got, c.want)
+		}
+	}
+}
+
+func TestRenderCachedSection(t *testing.T) {
+	htmlTpl, _ := templates.Read("", templates.HTML)
+	textTpl, _ := templates.Read("", templates.Text)
+	r, err := New(htmlTpl, textTpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := []fetcher.Result{{
+		Type:       fetcher.TypeWeather,
+		ID:         "weather",
+		Title:      "Weather",
+		Data:       []fetcher.WeatherData{{Temperature: 12, Description: "Cloudy"}},
+		CachedAt:   time.Now().Add(-24 * time.Hour),
+		FetchError: fmt.Errorf("HTTP 502"),
+	}}
+
+	email, err := r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(email.HTML, "from yesterday") || !strings.Contains(email.Text, "--- Weather (from yesterday) ---") {
+		t.Errorf("expected the section to be marked as cached, got text %q", email.Text)
+	}
+	if strings.Contains(email.HTML, "Could not load") {
+		t.Error("expected the cached copy instead of the error module")
+	}
+}
+
+func TestRenderWeatherForecast(t *testing.T) {
+	htmlTpl, _ := templates.Read("", templates.HTML)
+	textTpl, _ := templates.Read("", templates.Text)
+	r, err := New(htmlTpl, textTpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	results := []fetcher.Result{{
+		Type:  fetcher.TypeWeather,
+		ID:    "weather",
+		Title: "Weather",
+		Data: []fetcher.WeatherData{{
+			Temperature: 6,
+			Description: "Partly cloudy",
+			Hourly: []fetcher.HourlyWeather{
+				{Time: day.Add(7 * time.Hour), Temperature: 5, WeatherCode: 3, Description: "Partly cloudy", Precipitation: 10},
+				{Time: day.Add(16 * time.Hour), Temperature: 10, WeatherCode: 61, Description: "Rain", Precipitation: 60},
+			},
+			Outlook: []fetcher.DailyWeather{
+				{Date: day.AddDate(0, 0, 3), HighTemp: 12, LowTemp: 2, Description: "Clear sky"},
+			},
+			Umbrella: &fetcher.Umbrella{Needed: true, Chance: 60, At: day.Add(17 * time.Hour)},
+		}},
+	}}
+
+	email, err := r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"Take an umbrella: 60% chance of rain around 17:00.", "16:00", "Thu 5"} {
+		if !strings.Contains(email.HTML, want) {
+			t.Errorf("expected %q in the HTML", want)
+		}
+	}
+	for _, want := range []string{
+		"    16:00   10°   60%  Rain\n",
+		"    Thu 5      12° /   2°    0%  Clear sky\n",
+	} {
+		if !strings.Contains(email.Text, want) {
+			t.Errorf("expected %q in the text:\n%s", want, email.Text)
+		}
+	}
+}
+
+func TestRenderWeatherLocations(t *testing.T) {
+	htmlTpl, _ := templates.Read("", templates.HTML)
+	textTpl, _ := templates.Read("", templates.Text)
+	r, err := New(htmlTpl, textTpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := []fetcher.Result{{
+		Type:  fetcher.TypeWeather,
+		ID:    "weather",
+		Title: "Weather",
+		Data: []fetcher.WeatherData{
+			{Location: "Hameln", Temperature: 5, WindSpeed: 14, PrecipitationSum: 3.2, Description: "Rain",
+				Units: fetcher.WeatherUnits{Temperature: "°C", WindSpeed: "km/h", Precipitation: "mm"}},
+			{Location: "Office", Error: "Open-Meteo API returned status 502"},
+			{Location: "New York", Temperature: 41, WindSpeed: 9, PrecipitationSum: 0.12, Description: "Clear sky",
+				Units: fetcher.WeatherUnits{Temperature: "°F", WindSpeed: "mph", Precipitation: "in"}},
+		},
+	}}
+
+	email, err := r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"Hameln", "5°C", "14 km/h", "3.2 mm", "New York", "41°F", "9 mph", "0.12 in", "Office</span>", "Could not load the forecast"} {
+		if !strings.Contains(email.HTML, want) {
+			t.Errorf("expected %q in the HTML", want)
+		}
+	}
+	if strings.Contains(email.Text, "Office: 0.0") {
+		t.Errorf("expected no forecast for the failed location:\n%s", email.Text)
+	}
+	for _, want := range []string{"  Hameln: 5.0°C — Rain\n", "  Office: [Could not load the forecast]\n\n  New York: 41.0°F — Clear sky\n", "(0.12 in) | Wind: 9 mph\n"} {
+		if !strings.Contains(email.Text, want) {
+			t.Errorf("expected %q in the text:\n%s", want, email.Text)
+		}
+	}
+}
+
+func TestRenderAirQuality(t *testing.T) {
+	htmlTpl, _ := templates.Read("", templates.HTML)
+	textTpl, _ := templates.Read("", templates.Text)
+	r, err := New(htmlTpl, textTpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := []fetcher.Result{{
+		Type:  fetcher.TypeWeather,
+		ID:    "weather",
+		Title: "Weather",
+		Data: []fetcher.WeatherData{{
+			Temperature: 14,
+			AirQuality: &fetcher.AirQuality{
+				AQI:      &fetcher.Reading{Value: 45, Level: fetcher.LevelModerate},
+				PM25:     &fetcher.Reading{Value: 8},
+				UVIndex:  fetcher.Reading{Value: 6.5, Level: fetcher.LevelHigh},
+				Pollen:   []fetcher.Pollen{{Name: "Birch", Count: 140, Level: fetcher.LevelVeryHigh}},
+				Warnings: []string{"Birch pollen is very high (140 grains/m³)"},
+			},
+		}},
+	}}
+
+	email, err := r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"Air quality warning", "Birch pollen is very high", levelColor(fetcher.LevelVeryHigh), "Birch <strong"} {
+		if !strings.Contains(email.HTML, want) {
+			t.Errorf("expected %q in the HTML", want)
+		}
+	}
+	for _, want := range []string{
+		"  ! Birch pollen is very high (140 grains/m³)\n",
+		"  Air: AQI 45 (moderate) | PM2.5 8 µg/m³ (low) | UV 6 (high)\n",
+		"  Pollen: Birch 140 (very high)\n",
+	} {
+		if !strings.Contains(email.Text, want) {
+			t.Errorf("expected %q in the text:\n%s", want, email.Text)
+		}
+	}
+
+	// A station without current readings leaves AQI and PM2.5 out rather
+	// than showing them as zero.
+	aq := results[0].Data.([]fetcher.WeatherData)[0].AirQuality
+	aq.AQI, aq.PM25 = nil, nil
+	email, err = r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(email.HTML, "AQI") || strings.Contains(email.HTML, "PM2.5") {
+		t.Error("expected no AQI or PM2.5 in the HTML")
+	}
+	if !strings.Contains(email.Text, "  Air: UV 6 (high)\n") {
+		t.Errorf("expected only the UV index in the air line:\n%s", email.Text)
+	}
+}
+
+func TestRenderSky(t *testing.T) {
+	htmlTpl, _ := templates.Read("", templates.HTML)
+	textTpl, _ := templates.Read("", templates.Text)
+	r, err := New(htmlTpl, textTpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	day := time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC)
+	results := []fetcher.Result{{
+		Type:  fetcher.TypeWeather,
+		ID:    "weather",
+		Title: "Weather",
+		Data: []fetcher.WeatherData{{
+			Temperature: 1,
+			Sky: &fetcher.Sky{
+				Sunrise:           day.Add(8*time.Hour + 21*time.Minute),
+				Sunset:            day.Add(16*time.Hour + 37*time.Minute),
+				Daylight:          8*time.Hour + 16*time.Minute + 10*time.Second,
+				YesterdayDaylight: 8*time.Hour + 13*time.Minute + 30*time.Second,
+				Moon:              fetcher.MoonPhase{Phase: 1, Name: "Waxing crescent", Age: 0.15, Illumination: 20},
+			},
+		}},
+	}}
+
+	email, err := r.Render(results, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"Today&rsquo;s sky", "08:21", "16:37", "8h 16m of daylight", "2m 40s vs yesterday", "🌒 Waxing crescent (20% lit)"} {
+		if !strings.Contains(email.HTML, want) {
+			t.Errorf("expected %q in the HTML", want)
+		}
+	}
+	want := "  Today's sky: sunrise 08:21 | sunset 16:37 | 8h 16m of daylight (+2m 40s vs yesterday) | Waxing crescent (20% lit)\n"
+	if !strings.Contains(email.Text, want) {
+		t.Errorf("expected %q in the text:\n%s", want, email.Text)
+	}
+}
+
+func TestDayChange(t *testing.T) {
+	for _, c := range []struct {
+		today, yesterday time.Duration
+		want             string
+	}{
+		{10 * time.Hour, 0, ""},
+		{10 * time.Hour, 10*time.Hour - 42*time.Second, "+42s"},
+		{10 * time.Hour, 10*time.Hour + 3*time.Minute + 5*time.Second, "−3m 05s"},
+	} {
+		if got := dayChange(&fetcher.Sky{Daylight: c.today, YesterdayDaylight: c.yesterday}); got != c.want {
+			t.Errorf("dayChange(%v, %v) = %q, want %q", c.today, c.yesterday, got, c.want)
+		}
+	}
+}
+
+func TestUmbrellaLine(t *testing.T) {
+	at := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		umbrella *fetcher.Umbrella
+		want     string
+	}{
+		{nil, ""},
+		{&fetcher.Umbrella{Needed: true, Chance: 70, At: at}, "Take an umbrella: 70% chance of rain around 08:00."},
+		{&fetcher.Umbrella{Chance: 20, At: at}, "No umbrella needed on the commute (at most 20% chance of rain)."},
+	} {
+		if got := umbrellaLine(&fetcher.WeatherData{Umbrella: c.umbrella}); got != c.want {
+			t.Errorf("umbrellaLine(%+v) = %q, want %q", c.umbrella, got, c.want)
+		}
+	}
+}
+
+func TestDayLabel(t *testing.T) {
+	now := time.Date(2026, 3, 2, 23, 30, 0, 0, time.UTC)
+	if got := dayLabelAt(time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), now); got != "Tomorrow" {
+		t.Errorf("expected Tomorrow, got %q", got)
+	}
+	if got := dayLabelAt(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC), now); got != "Wed 4" {
+		t.Errorf("expected Wed 4, got %q", got)
+	}
+}
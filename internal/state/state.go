@@ -0,0 +1,323 @@
+// Package state persists what Burrow remembers between runs: the edition
+// counter, the items already delivered to each recipient, the fetch health of every
+// source, the places location names resolved to, and a log of past runs.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileName = "state.json"
+	// keepEditions bounds how long delivered item keys are remembered.
+	keepEditions = 30
+	// keepRuns bounds the run log.
+	keepRuns = 100
+)
+
+// Run is one entry of the run log.
+type Run struct {
+	// ID identifies the run in the log output.
+	ID       string    `json:"id,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Edition  int       `json:"edition"`
+	Sent     bool      `json:"sent"`
+	Error    string    `json:"error,omitempty"`
+	// Recipients lists the addresses the run tried to deliver to.
+	Recipients []string `json:"recipients,omitempty"`
+	// FailedSources maps source IDs to the error their fetch returned.
+	FailedSources map[string]string `json:"failed_sources,omitempty"`
+	// FailedRecipients maps addresses to the error their delivery returned.
+	FailedRecipients map[string]string `json:"failed_recipients,omitempty"`
+	// Sources maps the IDs of the sources fetched to how their fetch went.
+	Sources map[string]SourceStats `json:"sources,omitempty"`
+	// RenderTime is the time spent rendering the digests of all recipients.
+	RenderTime time.Duration `json:"render_time,omitempty"`
+}
+
+// SourceStats describes one source's fetch within a run.
+type SourceStats struct {
+	Type     string        `json:"type"`
+	Duration time.Duration `json:"duration"`
+	Items    int           `json:"items"`
+}
+
+// Health tracks whether a source's fetches have been failing.
+type Health struct {
+	// Failures counts the runs in a row the source failed in.
+	Failures int `json:"failures,omitempty"`
+	// FailingSince is when the first of those failures happened.
+	FailingSince time.Time `json:"failing_since,omitzero"`
+	LastError    string    `json:"last_error,omitempty"`
+	// Alerted is set once an alert about the failures went out.
+	Alerted bool `json:"alerted,omitempty"`
+	// RecoveredAt is when an alerted source worked again, while the notice
+	// that it recovered has not gone out yet.
+	RecoveredAt time.Time `json:"recovered_at,omitzero"`
+}
+
+// Place is a location name resolved to coordinates, as cached between runs.
+type Place struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone,omitempty"`
+	Region    string  `json:"region,omitempty"`
+	Country   string  `json:"country,omitempty"`
+}
+
+type document struct {
+	Edition int `json:"edition"`
+	// EditionDay is the local date, like "2026-03-02", the last edition
+	// went out on.
+	EditionDay string `json:"edition_day,omitempty"`
+	// DeliveredTo maps recipient address to source ID to item key to the
+	// edition the recipient got the item in.
+	DeliveredTo map[string]map[string]map[string]int `json:"delivered_to,omitempty"`
+	// Health maps source ID to its fetch health. Healthy sources are left out.
+	Health map[string]Health `json:"health,omitempty"`
+	// Places maps location names to the places they resolved to.
+	Places map[string]Place `json:"places,omitempty"`
+	Runs   []Run            `json:"runs"`
+}
+
+// Store is a small JSON file under the data directory. Every change is written
+// through to disk immediately, unless the store was opened read-only.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	fresh    bool
+	readOnly bool
+	data     document
+}
+
+// Open loads the store from dir, creating the directory if needed. A missing
+// state file yields an empty store that reports IsNew.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating data dir: %w", err)
+	}
+	return load(dir, false)
+}
+
+// OpenReadOnly loads the store from dir like Open, but never creates or writes
+// anything: changes only last as long as the store. A missing directory yields
+// an empty store.
+func OpenReadOnly(dir string) (*Store, error) {
+	return load(dir, true)
+}
+
+func load(dir string, readOnly bool) (*Store, error) {
+	s := &Store{path: filepath.Join(dir, fileName), readOnly: readOnly}
+	raw, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		s.fresh = true
+	case err != nil:
+		return nil, fmt.Errorf("reading state: %w", err)
+	default:
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, fmt.Errorf("parsing state %s: %w", s.path, err)
+		}
+	}
+	if s.data.DeliveredTo == nil {
+		s.data.DeliveredTo = make(map[string]map[string]map[string]int)
+	}
+	return s, nil
+}
+
+// IsNew reports whether the store was created by this process rather than
+// loaded from an existing file.
+func (s *Store) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fresh
+}
+
+// MigrateEdition seeds the edition counter from the legacy `edition:` config
+// key. It only has an effect on a new store.
+func (s *Store) MigrateEdition(edition int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fresh || edition <= s.data.Edition {
+		return nil
+	}
+	s.data.Edition = edition
+	return s.save()
+}
+
+// Edition returns the number of the last edition sent.
+func (s *Store) Edition() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Edition
+}
+
+// SetEdition records edition as the last one sent.
+func (s *Store) SetEdition(edition int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Edition = edition
+	return s.save()
+}
+
+// EditionOn returns the number of the edition delivered on day: the last
+// edition's if it went out the same day, so every schedule of a day shares
+// one edition, and otherwise the next one.
+func (s *Store) EditionOn(day time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.EditionDay == day.Format(time.DateOnly) {
+		return s.data.Edition
+	}
+	return s.data.Edition + 1
+}
+
+// SetEditionOn records edition as the last one sent, on day.
+func (s *Store) SetEditionOn(edition int, day time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Edition = edition
+	s.data.EditionDay = day.Format(time.DateOnly)
+	return s.save()
+}
+
+// MarkDelivered records the item keys a source delivered to a recipient in
+// edition. Keys delivered keep or more editions earlier are dropped, but
+// never before keepEditions, so keep only matters when it is larger.
+func (s *Store) MarkDelivered(recipient, sourceID string, keys []string, edition, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep = max(keep, keepEditions)
+
+	sources := s.data.DeliveredTo[recipient]
+	if sources == nil {
+		sources = make(map[string]map[string]int)
+		s.data.DeliveredTo[recipient] = sources
+	}
+	seen := sources[sourceID]
+	if seen == nil {
+		seen = make(map[string]int)
+		sources[sourceID] = seen
+	}
+	for _, k := range keys {
+		seen[k] = edition
+	}
+	for k, e := range seen {
+		if edition-e >= keep {
+			delete(seen, k)
+		}
+	}
+	return s.save()
+}
+
+// DeliveredIn returns the edition in which a source last delivered the item
+// with the given key to a recipient.
+func (s *Store) DeliveredIn(recipient, sourceID, key string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.data.DeliveredTo[recipient][sourceID][key]
+	return e, ok
+}
+
+// Health returns the fetch health of a source.
+func (s *Store) Health(sourceID string) Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Health[sourceID]
+}
+
+// SetHealth records the fetch health of sources by ID. A zero Health marks a
+// source as healthy.
+func (s *Store) SetHealth(health map[string]Health) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, h := range health {
+		if h == (Health{}) {
+			delete(s.data.Health, id)
+			continue
+		}
+		if s.data.Health == nil {
+			s.data.Health = make(map[string]Health)
+		}
+		s.data.Health[id] = h
+	}
+	return s.save()
+}
+
+// Place returns the place a location name resolved to, if it was looked up
+// before.
+func (s *Store) Place(query string) (Place, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.data.Places[query]
+	return p, ok
+}
+
+// SetPlace records the place a location name resolved to.
+func (s *Store) SetPlace(query string, p Place) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Places == nil {
+		s.data.Places = make(map[string]Place)
+	}
+	s.data.Places[query] = p
+	return s.save()
+}
+
+// RecordRun appends a run to the log, keeping the most recent keepRuns.
+func (s *Store) RecordRun(run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Runs = append(s.data.Runs, run)
+	if len(s.data.Runs) > keepRuns {
+		s.data.Runs = s.data.Runs[len(s.data.Runs)-keepRuns:]
+	}
+	return s.save()
+}
+
+// Runs returns the run log, oldest first.
+func (s *Store) Runs() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Run(nil), s.data.Runs...)
+}
+
+// save writes the state atomically via a temp file in the same directory.
+// Callers must hold s.mu.
+func (s *Store) save() error {
+	if s.readOnly {
+		return nil
+	}
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), fileName+".*")
+	if err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	s.fresh = false
+	return nil
+}
@@ -0,0 +1,200 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenNewAndMigrate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !s.IsNew() {
+		t.Error("expected a new store")
+	}
+	if err := s.MigrateEdition(41); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Edition() != 41 {
+		t.Errorf("expected edition 41, got %d", s.Edition())
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reopened.IsNew() {
+		t.Error("expected reopened store not to be new")
+	}
+	if err := reopened.MigrateEdition(7); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reopened.Edition() != 41 {
+		t.Errorf("expected migration to be skipped on existing store, got edition %d", reopened.Edition())
+	}
+}
+
+func TestSetEditionPersists(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+	if err := s.SetEdition(3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, _ := Open(dir)
+	if reopened.Edition() != 3 {
+		t.Errorf("expected edition 3, got %d", reopened.Edition())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the state file in the data dir, got %d entries", len(entries))
+	}
+}
+
+func TestEditionOn(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+	morning := time.Date(2026, 3, 2, 7, 0, 0, 0, time.Local)
+
+	if e := s.EditionOn(morning); e != 1 {
+		t.Fatalf("expected edition 1, got %d", e)
+	}
+	if err := s.SetEditionOn(1, morning); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, _ := Open(dir)
+	if e := reopened.EditionOn(morning.Add(11 * time.Hour)); e != 1 {
+		t.Errorf("expected the evening schedule to share edition 1, got %d", e)
+	}
+	if e := reopened.EditionOn(morning.AddDate(0, 0, 1)); e != 2 {
+		t.Errorf("expected edition 2 the next day, got %d", e)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+
+	s, err := OpenReadOnly(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.SetEdition(3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Edition() != 3 {
+		t.Errorf("expected the change to be kept in memory, got edition %d", s.Edition())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the data dir not to be created, got %v", err)
+	}
+
+	written, _ := Open(dir)
+	written.SetEdition(5)
+	s, err = OpenReadOnly(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.SetEdition(6)
+	if reopened, _ := Open(dir); reopened.Edition() != 5 {
+		t.Errorf("expected the state file to be left alone, got edition %d", reopened.Edition())
+	}
+}
+
+func TestDelivered(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+
+	if err := s.MarkDelivered("me@example.com", "hackernews", []string{"example.com/a", "example.com/b"}, 1, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.MarkDelivered("me@example.com", "hackernews", []string{"example.com/b"}, 2, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, _ := Open(dir)
+	if e, ok := reopened.DeliveredIn("me@example.com", "hackernews", "example.com/a"); !ok || e != 1 {
+		t.Errorf("expected example.com/a in edition 1, got %d, %v", e, ok)
+	}
+	if e, ok := reopened.DeliveredIn("me@example.com", "hackernews", "example.com/b"); !ok || e != 2 {
+		t.Errorf("expected example.com/b in edition 2, got %d, %v", e, ok)
+	}
+	if _, ok := reopened.DeliveredIn("me@example.com", "reddit", "example.com/a"); ok {
+		t.Error("expected delivered items to be tracked per source")
+	}
+	if _, ok := reopened.DeliveredIn("you@example.com", "hackernews", "example.com/a"); ok {
+		t.Error("expected delivered items to be tracked per recipient")
+	}
+
+	if err := reopened.MarkDelivered("me@example.com", "hackernews", nil, 1+keepEditions, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := reopened.DeliveredIn("me@example.com", "hackernews", "example.com/a"); ok {
+		t.Error("expected old keys to be pruned")
+	}
+}
+
+func TestDeliveredKeepsLongerWindow(t *testing.T) {
+	s, _ := Open(t.TempDir())
+	const keep = 2 * keepEditions
+
+	if err := s.MarkDelivered("me@example.com", "hackernews", []string{"example.com/a"}, 1, keep); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.MarkDelivered("me@example.com", "hackernews", nil, keep, keep); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := s.DeliveredIn("me@example.com", "hackernews", "example.com/a"); !ok {
+		t.Error("expected keys to be kept for the whole dedupe window")
+	}
+	if err := s.MarkDelivered("me@example.com", "hackernews", nil, 1+keep, keep); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := s.DeliveredIn("me@example.com", "hackernews", "example.com/a"); ok {
+		t.Error("expected keys older than the dedupe window to be pruned")
+	}
+}
+
+func TestPlaces(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+
+	hameln := Place{Name: "Hameln", Latitude: 52.1, Longitude: 9.36, Timezone: "Europe/Berlin", Country: "DE"}
+	if err := s.SetPlace("hameln, de", hameln); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, _ := Open(dir)
+	if p, ok := reopened.Place("hameln, de"); !ok || p != hameln {
+		t.Errorf("expected the resolved place, got %+v, %v", p, ok)
+	}
+	if _, ok := reopened.Place("berlin"); ok {
+		t.Error("expected no place for a name never resolved")
+	}
+}
+
+func TestRecordRun(t *testing.T) {
+	s, _ := Open(t.TempDir())
+	start := time.Date(2026, 1, 2, 7, 0, 0, 0, time.UTC)
+
+	for i := 0; i < keepRuns+5; i++ {
+		run := Run{Started: start, Finished: start.Add(time.Minute), Edition: i + 1, Sent: true}
+		if err := s.RecordRun(run); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	runs := s.Runs()
+	if len(runs) != keepRuns {
+		t.Fatalf("expected %d runs, got %d", keepRuns, len(runs))
+	}
+	if runs[len(runs)-1].Edition != keepRuns+5 {
+		t.Errorf("expected latest run last, got edition %d", runs[len(runs)-1].Edition)
+	}
+}
@@ -0,0 +1,39 @@
+// Package templates holds the default digest templates, embedded so the
+// binary runs without the template files next to it.
+package templates
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	HTML = "digest.html"
+	Text = "digest.txt"
+)
+
+//go:embed digest.html digest.txt
+var defaults embed.FS
+
+// Read returns the named template from dir if dir is set and contains it,
+// and the embedded default otherwise, so dir may override just one file.
+func Read(dir, name string) (string, error) {
+	if dir != "" {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(raw), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("reading template %s: %w", name, err)
+		}
+	}
+	raw, err := defaults.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("reading embedded template %s: %w", name, err)
+	}
+	return string(raw), nil
+}
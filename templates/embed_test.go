@@ -0,0 +1,39 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOverridesPerFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, Text), []byte("custom"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	text, err := Read(dir, Text)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "custom" {
+		t.Errorf("expected override, got %q", text)
+	}
+
+	html, err := Read(dir, HTML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(html, "<html") {
+		t.Errorf("expected embedded HTML template, got %q", html[:min(len(html), 40)])
+	}
+}
+
+func TestReadEmbedded(t *testing.T) {
+	for _, name := range []string{HTML, Text} {
+		if s, err := Read("", name); err != nil || s == "" {
+			t.Errorf("%s: expected embedded template, got %d bytes, %v", name, len(s), err)
+		}
+	}
+}